@@ -1,28 +1,159 @@
 package greatriverenergy
 
 import (
+	"io"
 	"net/http"
-	"net/http/cookiejar"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
+// DefaultBaseURL is the location of the load management site operated by Great River Energy.
+const DefaultBaseURL = "https://lmguide.grenergy.com"
+
+// Client retrieves data from the load management site. A Client is safe for concurrent use.
 type Client struct {
-	client http.Client
+	client   http.Client
+	baseURL  string
+	tolerant bool
+
+	fixedColumns bool
+
+	historyCache HistoryCache
+
+	retry    RetryPolicy
+	breaker  *CircuitBreaker
+	limiter  *RateLimiter
+	sessions *sessionPool
+
+	clock Clock
 }
 
-func NewClient(transport http.RoundTripper) *Client {
-	if transport == nil {
-		transport = http.DefaultTransport
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL directs the Client to a site other than DefaultBaseURL, e.g. a local stand-in server used for testing.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
 	}
+}
 
-	jar, err := cookiejar.New(nil)
-	if err != nil {
-		panic(err)
+func NewClient(transport http.RoundTripper, opts ...Option) *Client {
+	if transport == nil {
+		transport = http.DefaultTransport
 	}
 
+	// Cookies belong to sessions, so that one Client can be used by many goroutines at once
 	client := http.Client{
 		Transport:     transport,
 		CheckRedirect: nil,
-		Jar:           jar,
 	}
-	return &Client{client: client}
+	c := &Client{
+		client:  client,
+		baseURL: DefaultBaseURL,
+		clock:   SystemClock,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// pageURL returns the absolute URL of a page on the site, e.g. "Default.aspx"
+func (c Client) pageURL(page string) string {
+	return c.baseURL + "/" + page
+}
+
+// response is a page retrieved from the site
+type response struct {
+	*goquery.Document
+	// The time at which the site sent the page according to its Date header, or zero if unknown
+	date time.Time
+}
+
+// do performs a request for a page and parses the response as HTML, retrying according to the Client's RetryPolicy
+func (c Client) do(req *http.Request, page string) (*response, error) {
+	delay := c.retry.BaseDelay
+	for attempt := 1; ; attempt++ {
+		doc, err := c.doOnce(req, page)
+		if err == nil || attempt >= c.retry.Attempts || !retryable(err) {
+			return doc, err
+		}
+
+		wait, ok := c.retry.retryDelay(err, delay)
+		if deadline, hasDeadline := req.Context().Deadline(); !ok || hasDeadline && time.Until(deadline) < wait {
+			return nil, err
+		}
+		select {
+		case <-time.After(wait):
+			delay *= 2
+		case <-req.Context().Done():
+			return nil, err
+		}
+
+		// Rewind the body, if any
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// doOnce performs a single attempt at a request
+func (c Client) doOnce(req *http.Request, page string) (*response, error) {
+	if c.breaker != nil {
+		if err := c.breaker.allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	var doc *response
+	var err error
+	if c.limiter != nil {
+		err = c.limiter.wait(req.Context())
+	}
+	if err == nil {
+		doc, err = c.fetch(req, page)
+	}
+	if c.breaker != nil {
+		c.breaker.record(err)
+	}
+	return doc, err
+}
+
+// doStale performs a single attempt at a request which may fail because the site has forgotten the session it belongs
+// to, such as a form submitted from a pooled session. Such a failure says nothing about the site's health, so it is
+// neither retried nor recorded by the circuit breaker, though the request is still withheld while the breaker is open.
+func (c Client) doStale(req *http.Request, page string) (*response, error) {
+	if c.breaker != nil && c.breaker.State() != BreakerClosed {
+		return nil, ErrCircuitOpen
+	}
+	if c.limiter != nil {
+		if err := c.limiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	return c.fetch(req, page)
+}
+
+func (c Client) fetch(req *http.Request, page string) (*response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, &StatusError{Page: page, StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	date, _ := http.ParseTime(resp.Header.Get("Date"))
+	return &response{Document: doc, date: date}, nil
 }
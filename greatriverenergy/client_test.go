@@ -0,0 +1,30 @@
+package greatriverenergy
+
+import (
+	"testing"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+// newTestClient returns a Client pointed at a stand-in server, which is closed when the test completes
+func newTestClient(t *testing.T) (*Client, *lmguidetest.Server) {
+	t.Helper()
+	server := lmguidetest.NewServer()
+	t.Cleanup(server.Close)
+	return NewClient(nil, WithBaseURL(server.URL)), server
+}
+
+func TestWithBaseURL(t *testing.T) {
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "https://lmguide.grenergy.com/Default.aspx"},
+		{[]Option{WithBaseURL("http://127.0.0.1:8080")}, "http://127.0.0.1:8080/Default.aspx"},
+		{[]Option{WithBaseURL("http://127.0.0.1:8080/")}, "http://127.0.0.1:8080/Default.aspx"},
+	} {
+		if got := NewClient(nil, tc.opts...).pageURL("Default.aspx"); got != tc.want {
+			t.Errorf("pageURL() = %q, want %q", got, tc.want)
+		}
+	}
+}
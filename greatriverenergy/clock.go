@@ -0,0 +1,44 @@
+package greatriverenergy
+
+import "time"
+
+// Clock tells the time. A Client uses it to decide which days of history are complete, so that tests can control what
+// "now" means, and so that the past can be evaluated as if it were the present.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock reads the system's clock. It is used unless WithClock says otherwise.
+var SystemClock Clock = systemClock{}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// FixedClock returns a Clock which always reads t.
+func FixedClock(t time.Time) Clock {
+	return fixedClock(t)
+}
+
+// WithClock makes the Client read the time from clock rather than SystemClock.
+func WithClock(clock Clock) Option {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// now returns the time according to the Client's clock
+func (c Client) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
@@ -0,0 +1,28 @@
+package greatriverenergy
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithClock(t *testing.T) {
+	_, server := newTestClient(t)
+	cache := NewMemoryHistoryCache()
+	c := NewClient(nil, WithBaseURL(server.URL), WithHistoryCache(cache), WithClock(FixedClock(ymdhm(2022, 7, 15, 12, 0))))
+
+	history, err := c.History(context.Background(), HistoryTypeCI, ymd(2022, 7, 10), ymd(2022, 7, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// According to the clock, July 15 is today, so it and anything later is incomplete
+	if !history.EndOn.Equal(ymd(2022, 7, 15)) {
+		t.Errorf("EndOn = %v, want 2022-07-15", history.EndOn)
+	}
+	if _, ok := cache.GetDay(HistoryTypeCI, ymd(2022, 7, 14)); !ok {
+		t.Error("expected 2022-07-14 to be cached")
+	}
+	if _, ok := cache.GetDay(HistoryTypeCI, ymd(2022, 7, 15)); ok {
+		t.Error("expected 2022-07-15 not to be cached")
+	}
+}
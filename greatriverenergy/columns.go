@@ -0,0 +1,135 @@
+package greatriverenergy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// column is a column of a table on the site
+type column struct {
+	// The text of the column's header cell
+	header string
+	// The position at which the column has historically appeared, used with WithFixedColumns
+	position int
+}
+
+// tableLayout describes the columns we need from a table
+type tableLayout struct {
+	columns []column
+	// The number of columns the table has historically had, used with WithFixedColumns
+	width int
+}
+
+// The header text of each layout follows the synthetic pages in lmguidetest, and has not been checked against a
+// recording of the real site. Tolerant parsing falls back to the historical positions if it doesn't match.
+var (
+	scheduleLayout = tableLayout{
+		columns: []column{{"Class", 0}, {"Program Type", 1}, {"Probability", 2}, {"Expected Time", 3}},
+		width:   4,
+	}
+	shedCountsLayout = tableLayout{
+		columns: []column{{"Program", 0}, {"Shed Count", 2}},
+		width:   3,
+	}
+	historyLayout = tableLayout{
+		columns: []column{{"Date", 0}, {"Program", 1}, {"Start Time", 2}, {"End Time", 3}, {"Hours", 4}},
+		width:   5,
+	}
+)
+
+// WithFixedColumns parses tables by the positions their columns have historically occupied, ignoring the tables'
+// header rows. By default, columns are located by their header text, and a table without the expected headers is
+// reported as a ScrapeError, or when parsing tolerantly, as a Warning after which the historical positions are used.
+func WithFixedColumns() Option {
+	return func(c *Client) {
+		c.fixedColumns = true
+	}
+}
+
+// columnMap holds the position of each column in a tableLayout, in the same order
+type columnMap struct {
+	indexes []int
+	// The number of cells each row is expected to have
+	width int
+}
+
+// cells returns the text of each cell in a row, or an error if the row is the wrong shape
+func (m columnMap) cells(page, rowSelector string, tr *goquery.Selection) ([]string, *ScrapeError) {
+	cells := tr.Find("td").Map(func(_ int, td *goquery.Selection) string {
+		return td.Text()
+	})
+	if len(cells) != m.width {
+		return nil, scrapeError(page, "row", rowSelector, tr, "",
+			fmt.Errorf("expected %v cells, got %v", m.width, len(cells)))
+	}
+
+	out := make([]string, len(m.indexes))
+	for i, index := range m.indexes {
+		out[i] = cells[index]
+	}
+	return out, nil
+}
+
+// normalizeHeader makes header text comparable, ignoring case, whitespace and trailing colons
+func normalizeHeader(header string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(header), ":"))), " "))
+}
+
+// fixedColumns returns the positions the columns in a layout have historically occupied
+func (l tableLayout) fixedColumns() columnMap {
+	m := columnMap{width: l.width}
+	for _, column := range l.columns {
+		m.indexes = append(m.indexes, column.position)
+	}
+	return m
+}
+
+// mapColumns reads a table's header row to locate the columns in a layout. If the headers don't match when parsing
+// tolerantly, the historical positions are used instead.
+func (p *parser) mapColumns(page, tableSelector string, table *goquery.Selection, layout tableLayout) (columnMap, error) {
+	if p.fixedColumns {
+		return layout.fixedColumns(), nil
+	}
+
+	// The header row is the first row made of <th> cells, or failing that, the first row that isn't a data row
+	headerRow := table.Find("tr:has(th)").First()
+	if headerRow.Length() == 0 {
+		headerRow = table.Find("tr").Not(".BodyText_noSpaces").First()
+	}
+	headers := headerRow.Find("th, td").Map(func(_ int, cell *goquery.Selection) string {
+		return normalizeHeader(cell.Text())
+	})
+	if len(headers) == 0 {
+		if err := p.fail(scrapeError(page, "columns", tableSelector, table, "", fmt.Errorf("unable to find header row"))); err != nil {
+			return columnMap{}, err
+		}
+		return layout.fixedColumns(), nil
+	}
+
+	m := columnMap{width: len(headers)}
+	var missing []string
+	for _, column := range layout.columns {
+		index := -1
+		for i, header := range headers {
+			if header == normalizeHeader(column.header) {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			missing = append(missing, fmt.Sprintf("%q", column.header))
+		}
+		m.indexes = append(m.indexes, index)
+	}
+	if len(missing) > 0 {
+		if err := p.fail(scrapeError(page, "columns", tableSelector, headerRow, strings.Join(headers, " | "),
+			fmt.Errorf("missing expected column %s", strings.Join(missing, ", ")))); err != nil {
+			return columnMap{}, err
+		}
+		return layout.fixedColumns(), nil
+	}
+
+	return m, nil
+}
@@ -0,0 +1,80 @@
+package greatriverenergy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+func TestParseScheduleTable_ReorderedColumns(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table id="sched">
+	<tr class="HeaderText_noSpaces"><th>Probability</th><th>Expected Time</th><th>Program Type</th><th>Class</th></tr>
+	<tr class="BodyText_noSpaces"><td>Scheduled</td><td>03:00 PM - 07:00 PM</td><td>Interruptible Irrigation</td><td>CI</td></tr>
+</table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := (&parser{}).parseScheduleTable(doc, "#sched", ymd(2023, 7, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ProgramSchedule{{
+		Class:             ClassCI,
+		ProgramType:       "Interruptible Irrigation",
+		Date:              ymd(2023, 7, 8),
+		Probability:       ProbabilityScheduled,
+		ExpectedStartTime: ymdhm(2023, 7, 8, 15, 0),
+		ExpectedEndTime:   ymdhm(2023, 7, 8, 19, 0),
+		RawProbability:    "Scheduled",
+		RawExpectedTime:   "03:00 PM - 07:00 PM",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseScheduleTable() = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestClient_ShedCounts_MissingColumn(t *testing.T) {
+	c, server := newTestClient(t)
+	server.SetPage("ShedCount.aspx", bytes.Replace(lmguidetest.Fixture("ShedCount.aspx"), []byte("<th>Shed Count</th>"), []byte("<th>Times Shed</th>"), 1))
+
+	_, err := c.ShedCounts(context.Background())
+	var scrapeErr *ScrapeError
+	if !errors.As(err, &scrapeErr) || scrapeErr.Field != "columns" {
+		t.Fatalf("expected a ScrapeError for columns, got %v", err)
+	}
+	if !strings.Contains(scrapeErr.Error(), `"Shed Count"`) {
+		t.Errorf("error does not name the missing column: %v", scrapeErr)
+	}
+
+	// Tolerant parsing warns, and falls back to the historical positions
+	c = NewClient(nil, WithBaseURL(server.URL), WithTolerantParsing())
+	counts, err := c.ShedCounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Table["Dual Fuel"] != 214 {
+		t.Errorf("Table = %v", counts.Table)
+	}
+	if len(counts.Warnings) != 1 || counts.Warnings[0].Field != "columns" {
+		t.Errorf("Warnings = %+v, want one for columns", counts.Warnings)
+	}
+
+	// Fixed columns ignore the header
+	c = NewClient(nil, WithBaseURL(server.URL), WithFixedColumns())
+	counts, err = c.ShedCounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Table["Dual Fuel"] != 214 {
+		t.Errorf("Table = %v", counts.Table)
+	}
+}
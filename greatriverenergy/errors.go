@@ -0,0 +1,92 @@
+package greatriverenergy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ScrapeError indicates that a page was retrieved successfully but did not contain what was expected, which usually
+// means the site's layout has changed.
+//
+// Transport failures are returned as-is, and responses other than 200 OK are returned as a StatusError, so callers can
+// use errors.As to tell these cases apart.
+type ScrapeError struct {
+	// The page being scraped, e.g. "Default.aspx"
+	Page string
+	// A short identifier for the thing which could not be scraped, e.g. "conservation_gauge"
+	Field string
+	// The selector used to locate it
+	Selector string
+	// The offending text, if any
+	Text string
+	// A short excerpt of the HTML surrounding the problem
+	Excerpt string
+	// The underlying error
+	Err error
+}
+
+func (e *ScrapeError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "scrape failure on %s: %s", e.Page, e.Field)
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	if e.Text != "" {
+		fmt.Fprintf(&b, " (text %q)", e.Text)
+	}
+	return b.String()
+}
+
+func (e *ScrapeError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError indicates that the site responded with a status other than 200 OK.
+type StatusError struct {
+	Page       string
+	StatusCode int
+	// The delay requested by a Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: expected status code 200, got %v", e.Page, e.StatusCode)
+}
+
+const excerptLength = 256
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// excerpt renders the first node of a selection as HTML, with whitespace collapsed and truncated to a reasonable length
+func excerpt(selection *goquery.Selection) string {
+	if selection == nil || selection.Length() == 0 {
+		return ""
+	}
+
+	html, err := goquery.OuterHtml(selection.First())
+	if err != nil {
+		return ""
+	}
+	html = whitespace.ReplaceAllString(strings.TrimSpace(html), " ")
+	if len(html) > excerptLength {
+		html = strings.ToValidUTF8(html[:excerptLength], "") + "…"
+	}
+	return html
+}
+
+// scrapeError returns a ScrapeError for a page. The context selection should be the element which was found to be
+// wrong, or, if something could not be found, the element which should have contained it.
+func scrapeError(page, field, selector string, context *goquery.Selection, text string, err error) *ScrapeError {
+	return &ScrapeError{
+		Page:     page,
+		Field:    field,
+		Selector: selector,
+		Text:     text,
+		Excerpt:  excerpt(context),
+		Err:      err,
+	}
+}
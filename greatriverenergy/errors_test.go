@@ -0,0 +1,72 @@
+package greatriverenergy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+func TestScrapeError(t *testing.T) {
+	c, server := newTestClient(t)
+	server.SetPage("Default.aspx", bytes.Replace(lmguidetest.Fixture("Default.aspx"), []byte("gauge1.jpg"), []byte("gauge9.jpg"), 1))
+
+	_, err := c.Schedule(context.Background())
+
+	var scrapeErr *ScrapeError
+	if !errors.As(err, &scrapeErr) {
+		t.Fatalf("expected a ScrapeError, got %v", err)
+	}
+	if scrapeErr.Page != "Default.aspx" || scrapeErr.Field != "conservation_gauge" || scrapeErr.Text != "images/gauge9.jpg" {
+		t.Errorf("unexpected ScrapeError: %+v", scrapeErr)
+	}
+	if !strings.Contains(scrapeErr.Excerpt, `id="ContentPlaceHolder1_Gauge_Image"`) {
+		t.Errorf("excerpt does not contain the gauge: %q", scrapeErr.Excerpt)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		t.Errorf("ScrapeError should not be a StatusError")
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	c := NewClient(nil, WithBaseURL(server.URL))
+
+	_, err := c.ShedCounts(context.Background())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected a StatusError, got %v", err)
+	}
+	if statusErr.Page != "ShedCount.aspx" || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected StatusError: %+v", statusErr)
+	}
+
+	// History errors are wrapped, but must still be distinguishable
+	_, err = c.History(context.Background(), HistoryTypeR, ymd(2021, 7, 1), ymd(2021, 7, 4))
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected a StatusError, got %v", err)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	c := NewClient(nil, WithBaseURL(server.URL))
+
+	_, err := c.Schedule(context.Background())
+	var scrapeErr *ScrapeError
+	var statusErr *StatusError
+	if err == nil || errors.As(err, &scrapeErr) || errors.As(err, &statusErr) {
+		t.Errorf("expected a transport error, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package greatriverenergy
+
+import (
+	"sort"
+	"time"
+)
+
+// EventSet is a normalized collection of events. Events are ordered by class, program and start time, and events of
+// the same class and program which overlap or are contiguous are merged into one, so that each period of load
+// management appears exactly once.
+//
+// A merged event keeps the fields of the earliest event it contains, except that it ends with the latest one, and its
+// hours are recalculated. The zero value is an empty set.
+type EventSet struct {
+	events []HistoryEvent
+}
+
+// NewEventSet returns a normalized set of events.
+func NewEventSet(events ...HistoryEvent) EventSet {
+	sorted := append([]HistoryEvent(nil), events...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return eventLess(sorted[i], sorted[j])
+	})
+
+	var out []HistoryEvent
+	for _, event := range sorted {
+		if n := len(out); n > 0 && sameSeries(out[n-1], event) && !event.StartAt.After(out[n-1].EndAt) {
+			last := &out[n-1]
+			if event.EndAt.After(last.EndAt) {
+				last.EndAt = event.EndAt
+				last.UpstreamEndAt = event.UpstreamEndAt
+				last.EndMismatch = event.EndMismatch
+				last.Hours = last.EndAt.Sub(last.StartAt).Hours()
+				last.RawHours = ""
+			}
+			continue
+		}
+		out = append(out, event)
+	}
+	return EventSet{events: out}
+}
+
+// eventLess orders events by class, program, start and end
+func eventLess(a, b HistoryEvent) bool {
+	switch {
+	case a.Class != b.Class:
+		return a.Class < b.Class
+	case a.ProgramName != b.ProgramName:
+		return a.ProgramName < b.ProgramName
+	case !a.StartAt.Equal(b.StartAt):
+		return a.StartAt.Before(b.StartAt)
+	default:
+		return a.EndAt.Before(b.EndAt)
+	}
+}
+
+// sameSeries returns whether two events belong to the same class and program
+func sameSeries(a, b HistoryEvent) bool {
+	return a.Class == b.Class && a.ProgramName == b.ProgramName
+}
+
+// Events returns the events in the set, in order.
+func (s EventSet) Events() []HistoryEvent {
+	return append([]HistoryEvent(nil), s.events...)
+}
+
+// Len returns the number of events in the set.
+func (s EventSet) Len() int {
+	return len(s.events)
+}
+
+// Union returns a set containing the events of both sets.
+func (s EventSet) Union(other EventSet) EventSet {
+	return NewEventSet(append(s.Events(), other.events...)...)
+}
+
+// Clip returns the portions of the events in the set which fall within [start, end). Clipped events have their hours
+// recalculated.
+func (s EventSet) Clip(start, end time.Time) EventSet {
+	var out []HistoryEvent
+	for _, event := range s.events {
+		if !event.EndAt.After(start) || !event.StartAt.Before(end) {
+			continue
+		}
+
+		clipped := false
+		if event.StartAt.Before(start) {
+			event.StartAt = start
+			clipped = true
+		}
+		if event.EndAt.After(end) {
+			event.EndAt = end
+			clipped = true
+		}
+		if clipped {
+			event.Hours = event.EndAt.Sub(event.StartAt).Hours()
+			event.RawHours = ""
+		}
+		out = append(out, event)
+	}
+	return EventSet{events: out}
+}
+
+// ActiveAt returns the events in the set which are in progress at t, i.e. which started at or before t and end after
+// it.
+func (s EventSet) ActiveAt(t time.Time) []HistoryEvent {
+	var out []HistoryEvent
+	for _, event := range s.events {
+		if !event.StartAt.After(t) && event.EndAt.After(t) {
+			out = append(out, event)
+		}
+	}
+	return out
+}
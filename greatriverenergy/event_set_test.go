@@ -0,0 +1,90 @@
+package greatriverenergy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testEvent returns an event for a program between two times
+func testEvent(class, program string, startAt, endAt time.Time) HistoryEvent {
+	return HistoryEvent{
+		Class:       class,
+		ProgramName: program,
+		Date:        toMidnight(startAt),
+		Hours:       endAt.Sub(startAt).Hours(),
+		StartAt:     startAt,
+		EndAt:       endAt,
+	}
+}
+
+func TestNewEventSet(t *testing.T) {
+	a := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 1, 14, 0), ymdhm(2022, 7, 1, 16, 0))
+	b := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 1, 15, 0), ymdhm(2022, 7, 1, 18, 0))
+	c := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 1, 18, 0), ymdhm(2022, 7, 1, 19, 0))
+	d := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 2, 14, 0), ymdhm(2022, 7, 2, 16, 0))
+	e := testEvent("R", "Water Heating", ymdhm(2022, 6, 30, 14, 0), ymdhm(2022, 6, 30, 16, 0))
+	f := testEvent("CI", "Cycled Air Conditioning", ymdhm(2022, 7, 1, 14, 0), ymdhm(2022, 7, 1, 16, 0))
+
+	// Out of order, with duplicates, overlaps and a contiguous event
+	got := NewEventSet(d, e, c, a, b, a, f, d).Events()
+
+	merged := a
+	merged.EndAt = c.EndAt
+	merged.Hours = 5
+	want := []HistoryEvent{f, merged, d, e}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewEventSet() = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestEventSet_Union(t *testing.T) {
+	a := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 1, 14, 0), ymdhm(2022, 7, 1, 16, 0))
+	b := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 1, 16, 0), ymdhm(2022, 7, 1, 18, 0))
+
+	s := NewEventSet(a)
+	got := s.Union(NewEventSet(b))
+	if got.Len() != 1 || !got.Events()[0].EndAt.Equal(b.EndAt) {
+		t.Errorf("Union() = %+v, want one event ending at %v", got.Events(), b.EndAt)
+	}
+	if s.Len() != 1 || !s.Events()[0].EndAt.Equal(a.EndAt) {
+		t.Errorf("Union() modified its receiver: %+v", s.Events())
+	}
+}
+
+func TestEventSet_Clip(t *testing.T) {
+	a := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 1, 14, 0), ymdhm(2022, 7, 1, 18, 0))
+	b := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 2, 14, 0), ymdhm(2022, 7, 2, 18, 0))
+	c := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 3, 14, 0), ymdhm(2022, 7, 3, 18, 0))
+
+	got := NewEventSet(a, b, c).Clip(ymdhm(2022, 7, 1, 16, 0), ymdhm(2022, 7, 3, 14, 0)).Events()
+
+	clipped := a
+	clipped.StartAt = ymdhm(2022, 7, 1, 16, 0)
+	clipped.Hours = 2
+	want := []HistoryEvent{clipped, b}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Clip() = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestEventSet_ActiveAt(t *testing.T) {
+	a := testEvent("R", "Cycled Air Conditioning", ymdhm(2022, 7, 1, 14, 0), ymdhm(2022, 7, 1, 18, 0))
+	b := testEvent("R", "Water Heating", ymdhm(2022, 7, 1, 16, 0), ymdhm(2022, 7, 1, 20, 0))
+	s := NewEventSet(a, b)
+
+	for _, test := range []struct {
+		t    time.Time
+		want []HistoryEvent
+	}{
+		{ymdhm(2022, 7, 1, 13, 59), nil},
+		{ymdhm(2022, 7, 1, 14, 0), []HistoryEvent{a}},
+		{ymdhm(2022, 7, 1, 17, 0), []HistoryEvent{a, b}},
+		{ymdhm(2022, 7, 1, 18, 0), []HistoryEvent{b}},
+		{ymdhm(2022, 7, 1, 20, 0), nil},
+	} {
+		if got := s.ActiveAt(test.t); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ActiveAt(%v) = %+v, want %+v", test.t, got, test.want)
+		}
+	}
+}
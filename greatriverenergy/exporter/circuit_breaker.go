@@ -0,0 +1,35 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+// CircuitBreaker reports the state of a greatriverenergy.CircuitBreaker. Pass the breaker to the collectors with
+// WithClientOptions(greatriverenergy.WithCircuitBreaker(...)), and register this to export its state.
+type CircuitBreaker struct {
+	breaker *greatriverenergy.CircuitBreaker
+
+	state *prometheus.Desc
+}
+
+func NewCircuitBreaker(breaker *greatriverenergy.CircuitBreaker) CircuitBreaker {
+	return CircuitBreaker{
+		breaker: breaker,
+
+		state: prometheus.NewDesc("greatriverenergy_circuit_breaker_state",
+			"The state of the circuit breaker which stops requests to the site after repeated failures. 0 = Closed, 1 = Half-open, 2 = Open",
+			nil, nil,
+		),
+	}
+}
+
+func (c CircuitBreaker) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.state
+}
+
+func (c CircuitBreaker) Collect(metrics chan<- prometheus.Metric) {
+	metrics <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, float64(c.breaker.State()))
+}
+
+var _ prometheus.Collector = &CircuitBreaker{}
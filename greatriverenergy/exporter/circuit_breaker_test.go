@@ -0,0 +1,25 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	server, opt := newTestServer(t)
+	breaker := greatriverenergy.NewCircuitBreaker(1, time.Hour)
+	opts := []Option{opt, WithClientOptions(greatriverenergy.WithCircuitBreaker(breaker))}
+
+	gather(t, NewRealtime(nil, opts...))
+	if got := gaugeValues(gather(t, NewCircuitBreaker(breaker))["greatriverenergy_circuit_breaker_state"]); got[""] != 0 {
+		t.Errorf("circuit_breaker_state = %v, want 0", got)
+	}
+
+	server.Close()
+	gather(t, NewRealtime(nil, opts...))
+	if got := gaugeValues(gather(t, NewCircuitBreaker(breaker))["greatriverenergy_circuit_breaker_state"]); got[""] != 2 {
+		t.Errorf("circuit_breaker_state = %v, want 2", got)
+	}
+}
@@ -0,0 +1,51 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// scrapeTimeoutOffset is the portion of a scrape's timeout reserved for sending the response
+const scrapeTimeoutOffset = 500 * time.Millisecond
+
+// ScrapeContext returns a context for collecting metrics in response to a request from Prometheus. The context is
+// done when the request is cancelled, or shortly before the deadline given by the X-Prometheus-Scrape-Timeout-Seconds
+// header, so that whatever has been collected by then can still be returned.
+func ScrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx := r.Context()
+
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > 2*scrapeTimeoutOffset {
+		timeout -= scrapeTimeoutOffset
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// WithContext returns a copy of the collector which passes ctx to every request it makes. When ctx is done, Collect
+// returns whatever it has collected so far.
+func (c Realtime) WithContext(ctx context.Context) Realtime {
+	c.ctx = ctx
+	return c
+}
+
+// WithContext returns a copy of the collector which passes ctx to every request it makes. When ctx is done, Collect
+// returns whatever it has collected so far.
+func (c History) WithContext(ctx context.Context) History {
+	c.ctx = ctx
+	return c
+}
+
+// collectContext returns ctx, or the background context if it is unset
+func collectContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
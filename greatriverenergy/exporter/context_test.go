@@ -0,0 +1,93 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrapeContext(t *testing.T) {
+	for header, want := range map[string]time.Duration{
+		"":      0,
+		"bogus": 0,
+		"10":    9500 * time.Millisecond,
+		"0.5":   500 * time.Millisecond,
+	} {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if header != "" {
+			r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", header)
+		}
+
+		ctx, cancel := ScrapeContext(r)
+		deadline, ok := ctx.Deadline()
+		cancel()
+		if want == 0 {
+			if ok {
+				t.Errorf("ScrapeContext(%q) has deadline %v, want none", header, deadline)
+			}
+			continue
+		}
+		if got := time.Until(deadline); !ok || got > want || got < want-time.Second {
+			t.Errorf("ScrapeContext(%q) deadline in %v, want %v", header, got, want)
+		}
+	}
+}
+
+// hangingTransport never answers requests for history
+type hangingTransport struct{}
+
+func (hangingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.URL.Path, "History") {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRealtime_WithContext(t *testing.T) {
+	_, opt := newTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	families := gather(t, NewRealtime(hangingTransport{}, opt).WithContext(ctx))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Collect() took %v", elapsed)
+	}
+
+	// Everything but history should still be reported
+	if got := gaugeValues(families["greatriverenergy_conservation_gauge"]); got[""] != 1 {
+		t.Errorf("conservation_gauge = %v, want 1", got)
+	}
+	success := gaugeValues(families["greatriverenergy_scrape_success"])
+	for labels, want := range map[string]float64{
+		`page="Default.aspx"`:         1,
+		`page="ShedCount.aspx"`:       1,
+		`page="HistoryForm.aspx/RES"`: 0,
+		`page="HistoryForm.aspx/CI"`:  0,
+	} {
+		if got, ok := success[labels]; !ok || got != want {
+			t.Errorf("scrape_success{%s} = %v, want %v", labels, got, want)
+		}
+	}
+}
+
+func TestHistory_WithContext(t *testing.T) {
+	_, opt := newTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	families := gather(t, NewHistory(hangingTransport{}, 3650, opt).WithContext(ctx))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Collect() took %v", elapsed)
+	}
+	if _, ok := families["greatriverenergy_shed_event"]; ok {
+		t.Error("shed_event should be omitted when history could not be retrieved")
+	}
+}
@@ -0,0 +1,42 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+// errorKind classifies an error returned by a greatriverenergy.Client
+func errorKind(err error) string {
+	var scrapeErr *greatriverenergy.ScrapeError
+	var statusErr *greatriverenergy.StatusError
+	switch {
+	case errors.As(err, &scrapeErr):
+		return "scrape"
+	case errors.As(err, &statusErr):
+		return "status"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "transport"
+	}
+}
+
+// logFailure logs a failed call, including the HTML surrounding the problem if the site's layout seems to have changed
+func logFailure(call string, err error) {
+	var scrapeErr *greatriverenergy.ScrapeError
+	if errors.As(err, &scrapeErr) && scrapeErr.Excerpt != "" {
+		log.Printf("%s failed (%s error): %v; selector %q matched %s", call, errorKind(err), err, scrapeErr.Selector, scrapeErr.Excerpt)
+	} else {
+		log.Printf("%s failed (%s error): %v", call, errorKind(err), err)
+	}
+}
+
+// logWarnings logs anything a call was unable to parse
+func logWarnings(call string, warnings []greatriverenergy.Warning) {
+	for _, w := range warnings {
+		log.Printf("%s warning: %v", call, w)
+	}
+}
@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/recorder"
+)
+
+// newTestServer starts a stand-in server, returning it along with options which direct collectors to it
+func newTestServer(t *testing.T) (*lmguidetest.Server, Option) {
+	t.Helper()
+	server := lmguidetest.NewServer()
+	t.Cleanup(server.Close)
+	return server, WithClientOptions(greatriverenergy.WithBaseURL(server.URL))
+}
+
+// gather registers a collector with a fresh registry, collects it, and returns the metric families by name
+func gather(t *testing.T, collector prometheus.Collector) map[string]*dto.MetricFamily {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		out[family.GetName()] = family
+	}
+	return out
+}
+
+// labelString renders a metric's labels like `class="R",program="Dual Fuel"`
+func labelString(m *dto.Metric) string {
+	var parts []string
+	for _, label := range m.GetLabel() {
+		parts = append(parts, label.GetName()+"="+`"`+label.GetValue()+`"`)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// gaugeValues returns the value of each sample in a gauge or counter family, keyed by labelString
+func gaugeValues(family *dto.MetricFamily) map[string]float64 {
+	out := make(map[string]float64)
+	if family == nil {
+		return out
+	}
+	for _, m := range family.GetMetric() {
+		if m.Gauge != nil {
+			out[labelString(m)] = m.GetGauge().GetValue()
+		} else if m.Counter != nil {
+			out[labelString(m)] = m.GetCounter().GetValue()
+		}
+	}
+	return out
+}
+
+func TestCollect_Replay(t *testing.T) {
+	dir := t.TempDir()
+	server, opt := newTestServer(t)
+
+	rec, err := recorder.NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	days := int(time.Since(time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	recorded := []map[string]*dto.MetricFamily{
+		gather(t, NewRealtime(rec, opt)),
+		gather(t, NewHistory(rec, days, opt)),
+	}
+
+	server.Close()
+	replayer, err := recorder.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := []map[string]*dto.MetricFamily{
+		gather(t, NewRealtime(replayer, opt)),
+		gather(t, NewHistory(replayer, days, opt)),
+	}
+
+	for i := range recorded {
+		if len(recorded[i]) == 0 {
+			t.Errorf("collector %v recorded no metrics", i)
+		}
+		for name, family := range recorded[i] {
+			// These report when and how quickly the collection happened, not what was collected
+			if name == "greatriverenergy_last_success_timestamp_seconds" || name == "greatriverenergy_scrape_duration_seconds" {
+				continue
+			}
+			if got, want := replayed[i][name].String(), family.String(); got != want {
+				t.Errorf("replayed %s differs:\n%s\nwant\n%s", name, got, want)
+			}
+		}
+	}
+	if got := replayer.Remaining(); got != 0 {
+		t.Errorf("%v pairs were not replayed", got)
+	}
+}
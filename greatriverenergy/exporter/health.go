@@ -0,0 +1,108 @@
+package exporter
+
+import (
+	"errors"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+// historyPageLabel returns the page label for retrievals of a type of history
+func historyPageLabel(historyType greatriverenergy.HistoryType) string {
+	return historyPage + "/" + string(historyType)
+}
+
+// health describes how well each retrieval from the site is going, so that failures can be told apart from an absence
+// of data
+type health struct {
+	success       *prometheus.GaugeVec
+	duration      *prometheus.GaugeVec
+	parseErrors   *prometheus.CounterVec
+	parseWarnings *prometheus.GaugeVec
+}
+
+func newHealth() *health {
+	return &health{
+		success: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "greatriverenergy_scrape_success",
+			Help: "Whether the most recent retrieval of a page succeeded",
+		}, []string{"page"}),
+		duration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "greatriverenergy_scrape_duration_seconds",
+			Help: "The time taken by the most recent retrieval of a page",
+		}, []string{"page"}),
+		parseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "greatriverenergy_parse_errors_total",
+			Help: "The number of retrievals of a page which failed because it could not be parsed, by the field which could not be parsed",
+		}, []string{"page", "reason"}),
+		parseWarnings: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "greatriverenergy_parse_warnings",
+			Help: "The number of values which could not be parsed and were skipped in the most recent retrieval of a page, by the field which could not be parsed",
+		}, []string{"page", "reason"}),
+	}
+}
+
+// observe records the outcome of a retrieval which began at start
+func (h *health) observe(page string, start time.Time, err error, warnings []greatriverenergy.Warning) {
+	h.duration.WithLabelValues(page).Set(time.Since(start).Seconds())
+	if err != nil {
+		h.success.WithLabelValues(page).Set(0)
+	} else {
+		h.success.WithLabelValues(page).Set(1)
+	}
+
+	var scrapeErr *greatriverenergy.ScrapeError
+	if errors.As(err, &scrapeErr) {
+		h.parseErrors.WithLabelValues(page, scrapeErr.Field).Inc()
+	}
+
+	// Warnings are reported for the most recent retrieval only, since a page which keeps warning about the same value
+	// would otherwise count up forever
+	h.parseWarnings.DeletePartialMatch(prometheus.Labels{"page": page})
+	for _, w := range warnings {
+		h.parseWarnings.WithLabelValues(page, w.Field).Inc()
+	}
+}
+
+func (h *health) Describe(descs chan<- *prometheus.Desc) {
+	h.success.Describe(descs)
+	h.duration.Describe(descs)
+	h.parseErrors.Describe(descs)
+	h.parseWarnings.Describe(descs)
+}
+
+func (h *health) Collect(metrics chan<- prometheus.Metric) {
+	h.success.Collect(metrics)
+	h.duration.Collect(metrics)
+	h.parseErrors.Collect(metrics)
+	h.parseWarnings.Collect(metrics)
+}
+
+// NewBuildInfo returns a collector reporting greatriverenergy_exporter_build_info, whose labels describe the running
+// binary.
+func NewBuildInfo() prometheus.Collector {
+	version, revision := "unknown", "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "greatriverenergy_exporter_build_info",
+		Help: "A metric with a constant '1' value labeled by the version and revision from which the exporter was built, and the Go version used to build it",
+		ConstLabels: prometheus.Labels{
+			"version":   version,
+			"revision":  revision,
+			"goversion": runtime.Version(),
+		},
+	})
+	buildInfo.Set(1)
+	return buildInfo
+}
@@ -0,0 +1,17 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewBuildInfo(t *testing.T) {
+	families := gather(t, NewBuildInfo())
+	metrics := families["greatriverenergy_exporter_build_info"].GetMetric()
+	if len(metrics) != 1 || metrics[0].GetGauge().GetValue() != 1 {
+		t.Fatalf("build_info = %v", metrics)
+	}
+	if labels := labelString(metrics[0]); !strings.Contains(labels, `goversion="go`) {
+		t.Errorf("build_info labels = %v", labels)
+	}
+}
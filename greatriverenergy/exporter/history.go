@@ -1,31 +1,41 @@
 package exporter
 
 import (
+	"bufio"
 	"context"
-	"log"
+	"fmt"
+	"io"
 	"net/http"
-	"reflect"
-	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
 )
 
+const (
+	shedEventName = "greatriverenergy_shed_event"
+	shedEventHelp = "A load shedding event that occurred"
+)
+
 type History struct {
-	rt         http.RoundTripper
+	client     *greatriverenergy.Client
 	daysInPast int
+	opts       options
+	ctx        context.Context
 
 	shedEvent *prometheus.Desc
 }
 
-func NewHistory(rt http.RoundTripper, daysInPast int) History {
+func NewHistory(rt http.RoundTripper, daysInPast int, opts ...Option) History {
+	o := newOptions(opts)
 	return History{
-		rt:         rt,
+		client:     o.newClient(rt),
 		daysInPast: daysInPast,
+		opts:       o,
 
-		shedEvent: prometheus.NewDesc("greatriverenergy_shed_event",
-			"A load shedding event that occurred",
+		shedEvent: prometheus.NewDesc(shedEventName, shedEventHelp,
 			[]string{"class", "program"}, nil,
 		),
 	}
@@ -36,86 +46,129 @@ func (c History) Describe(descs chan<- *prometheus.Desc) {
 }
 
 func (c History) Collect(metrics chan<- prometheus.Metric) {
-	ctx := context.Background()
+	_ = c.each(func(class, program string, t time.Time, value float64) error {
+		metrics <- prometheus.NewMetricWithTimestamp(t, prometheus.MustNewConstMetric(c.shedEvent, prometheus.GaugeValue, value, class, program))
+		return nil
+	})
+}
 
-	// Calculate the date range to request
-	start := time.Now().AddDate(0, 0, -c.daysInPast)
-	end := time.Now().AddDate(0, 0, 1)
-
-	for historyType, class := range map[greatriverenergy.HistoryType]string{
-		greatriverenergy.HistoryTypeR:  "R",
-		greatriverenergy.HistoryTypeCI: "CI",
-	} {
-		// Use a new client to get this history, since history retrieval is stateful
-		history, err := greatriverenergy.NewClient(c.rt).History(ctx, historyType, start, end)
-		if err != nil {
-			log.Printf("History(%q) failed: %v", historyType, err)
-			continue
-		}
+// WriteTo writes the history to w in the Prometheus text format as it is retrieved. Unlike gathering the collector
+// with a prometheus.Registry, which holds every sample in memory until it can sort them, this keeps memory use flat
+// however many days are requested. Samples are grouped by history type rather than sorted, and each series is in
+// order.
+func (c History) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	write := func(format string, args ...interface{}) error {
+		written, err := fmt.Fprintf(bw, format, args...)
+		n += int64(written)
+		return err
+	}
 
-		// Sort by event name and then by start time
-		sort.Slice(history.Events, func(i, j int) bool {
-			if history.Events[i].ProgramName < history.Events[j].ProgramName {
-				return true
-			}
-			if history.Events[i].StartAt.Before(history.Events[j].StartAt) {
-				return true
+	header := false
+	err := c.each(func(class, program string, t time.Time, value float64) error {
+		if !header {
+			header = true
+			if err := write("# HELP %s %s\n# TYPE %s gauge\n", shedEventName, shedEventHelp, shedEventName); err != nil {
+				return err
 			}
-			return false
-		})
+		}
+		return write("%s{class=\"%s\",program=\"%s\"} %s %d\n", shedEventName, labelEscaper.Replace(class), labelEscaper.Replace(program), strconv.FormatFloat(value, 'g', -1, 64), t.UnixMilli())
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
 
-		history.Events = deduplicateSortedEvents(history.Events)
+// labelEscaper escapes a label value for the Prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// each retrieves the history, calling fn with each sample in order for each series. Retrieval failures are logged and
+// skipped, but if fn returns an error, each stops and returns it.
+func (c History) each(fn func(class, program string, t time.Time, value float64) error) error {
+	ctx := collectContext(c.ctx)
+
+	// Calculate the date range to request
+	now := c.opts.now()
+	start := now.AddDate(0, 0, -c.daysInPast)
+	end := now.AddDate(0, 0, 1)
+
+	for _, historyType := range c.opts.historyTypes(ctx, c.client) {
+		class := historyType.Class()
 
 		lastEventByProgram := make(map[string]time.Time)
 
+		// The first error returned by fn, after which nothing more is emitted
+		var emitErr error
 		emit := func(program string, t time.Time, value float64) {
-			if !lastEventByProgram[program].Before(t) {
+			if emitErr != nil || !lastEventByProgram[program].Before(t) {
 				return
 			}
 
 			lastEventByProgram[program] = t
-			metrics <- prometheus.NewMetricWithTimestamp(t, prometheus.MustNewConstMetric(c.shedEvent, prometheus.GaugeValue, value, class, program))
+			emitErr = fn(class, program, t, value)
 		}
 
-		for i, event := range history.Events {
-			// Emit a 0 before
-			emit(event.ProgramName, event.StartAt.Add(-time.Minute), 0)
+		// The end of each program's most recent event, which gets a trailing 0 unless another event overlaps or
+		// continues it, possibly in a later window
+		openEndByProgram := make(map[string]time.Time)
+		var endOn time.Time
+
+		// Stream the history in windows, so that years of events needn't be held in memory at once. The client
+		// already retries each request, so don't retry windows on top of that.
+		rangeOpts := greatriverenergy.HistoryRangeOptions{Concurrency: c.opts.maxConcurrency, Retries: -1}
+		err := c.client.HistoryEach(ctx, historyType, start, end, rangeOpts, func(history *greatriverenergy.History) error {
+			logWarnings(fmt.Sprintf("History(%q)", historyType), history.Warnings)
+			endOn = history.EndOn
+
+			// Normalize the window, which leaves each program's events in order, without duplicates or overlaps
+			events := greatriverenergy.NewEventSet(history.Events...).Events()
+
+			for _, event := range events {
+				if openEnd, ok := openEndByProgram[event.ProgramName]; ok && event.StartAt.After(openEnd) {
+					// The previous event neither overlaps nor is contiguous with this one, so it definitely ended
+					// Emit a 0 after
+					emit(event.ProgramName, openEnd.Add(time.Minute), 0)
+					delete(openEndByProgram, event.ProgramName)
+				}
 
-			// Emit a 1 for each minute the event occurred
-			for t := event.StartAt; t.Before(event.EndAt); t = t.Add(time.Minute) {
-				emit(event.ProgramName, t, 1)
-			}
+				// Emit a 0 before
+				emit(event.ProgramName, event.StartAt.Add(-time.Minute), 0)
 
-			if len(history.Events) > i+1 {
-				nextEvent := history.Events[i+1]
-				if nextEvent.ProgramName == event.ProgramName && !nextEvent.StartAt.After(event.EndAt) {
-					// The next event is either overlapping or contiguous
-					// Don't emit the trailing 0 event
-					continue
+				// Emit a 1 for each minute the event occurred, counting elapsed time rather than the clock
+				for t := event.StartAt; t.Before(event.EndAt); t = t.Add(time.Minute) {
+					emit(event.ProgramName, t, 1)
+				}
+				if emitErr != nil {
+					return emitErr
+				}
+
+				if event.EndAt.After(openEndByProgram[event.ProgramName]) {
+					openEndByProgram[event.ProgramName] = event.EndAt
 				}
 			}
+			return nil
+		})
+		if emitErr != nil {
+			return emitErr
+		} else if err != nil {
+			logFailure(fmt.Sprintf("History(%q)", historyType), err)
+			continue
+		}
 
-			if event.EndAt.Add(time.Minute).Before(history.EndOn) {
+		for program, openEnd := range openEndByProgram {
+			if openEnd.Add(time.Minute).Before(endOn) {
 				// We can be pretty confident that this was actually the end of the load management event
 				// Emit a 0 after
-				emit(event.ProgramName, event.EndAt.Add(time.Minute), 0)
+				emit(program, openEnd.Add(time.Minute), 0)
 			}
 		}
-	}
-}
-
-func deduplicateSortedEvents(events []greatriverenergy.HistoryEvent) []greatriverenergy.HistoryEvent {
-	var out []greatriverenergy.HistoryEvent
-	var lastEvent greatriverenergy.HistoryEvent
-	for _, event := range events {
-		if reflect.DeepEqual(event, lastEvent) {
-			continue
+		if emitErr != nil {
+			return emitErr
 		}
-
-		lastEvent = event
-		out = append(out, event)
 	}
-	return out
+	return nil
 }
 
 var _ prometheus.Collector = &History{}
@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+// HistoryCache wraps a greatriverenergy.HistoryCache, counting the days which were and weren't found in it. Pass it to
+// the collectors with WithClientOptions(greatriverenergy.WithHistoryCache(...)), and register it to export the counts.
+type HistoryCache struct {
+	cache greatriverenergy.HistoryCache
+
+	hits   *prometheus.CounterVec
+	misses *prometheus.CounterVec
+}
+
+func NewHistoryCache(cache greatriverenergy.HistoryCache) *HistoryCache {
+	return &HistoryCache{
+		cache: cache,
+
+		hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "greatriverenergy_history_cache_hits_total",
+			Help: "The number of days of history which were found in the cache",
+		}, []string{"class"}),
+		misses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "greatriverenergy_history_cache_misses_total",
+			Help: "The number of days of history which were not found in the cache, and had to be retrieved",
+		}, []string{"class"}),
+	}
+}
+
+func (c *HistoryCache) GetDay(historyType greatriverenergy.HistoryType, day time.Time) ([]greatriverenergy.HistoryEvent, bool) {
+	events, ok := c.cache.GetDay(historyType, day)
+	if ok {
+		c.hits.WithLabelValues(historyType.Class()).Inc()
+	} else {
+		c.misses.WithLabelValues(historyType.Class()).Inc()
+	}
+	return events, ok
+}
+
+func (c *HistoryCache) PutDay(historyType greatriverenergy.HistoryType, day time.Time, events []greatriverenergy.HistoryEvent) error {
+	return c.cache.PutDay(historyType, day, events)
+}
+
+func (c *HistoryCache) Describe(descs chan<- *prometheus.Desc) {
+	c.hits.Describe(descs)
+	c.misses.Describe(descs)
+}
+
+func (c *HistoryCache) Collect(metrics chan<- prometheus.Metric) {
+	c.hits.Collect(metrics)
+	c.misses.Collect(metrics)
+}
+
+var _ greatriverenergy.HistoryCache = &HistoryCache{}
+var _ prometheus.Collector = &HistoryCache{}
@@ -0,0 +1,31 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+func TestHistoryCache(t *testing.T) {
+	_, opt := newTestServer(t)
+	cache := NewHistoryCache(greatriverenergy.NewMemoryHistoryCache())
+	opts := []Option{opt, WithClientOptions(greatriverenergy.WithHistoryCache(cache))}
+
+	days := int(time.Since(time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	first := gather(t, NewHistory(nil, days, opts...))
+	second := gather(t, NewHistory(nil, days, opts...))
+	if got, want := len(second["greatriverenergy_shed_event"].GetMetric()), len(first["greatriverenergy_shed_event"].GetMetric()); got != want {
+		t.Errorf("cached collection has %v samples, want %v", got, want)
+	}
+
+	families := gather(t, cache)
+	hits := gaugeValues(families["greatriverenergy_history_cache_hits_total"])
+	misses := gaugeValues(families["greatriverenergy_history_cache_misses_total"])
+	for _, class := range []string{`class="R"`, `class="CI"`} {
+		// Every complete day missed the first time, and hit the second time
+		if hits[class] == 0 || hits[class] != misses[class] {
+			t.Errorf("{%s} hits = %v, misses = %v", class, hits[class], misses[class])
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package exporter
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+func TestHistory_Collect(t *testing.T) {
+	server, opt := newTestServer(t)
+	server.SetHistory("CPP", []lmguidetest.HistoryRow{
+		{Date: "07/03/2023", Program: "Critical Peak Pricing", Start: "16:00", End: "19:00", Hours: "3"},
+	})
+
+	// Reach back far enough to include the 07/03/2023 events, but not the ones from earlier years
+	days := int(time.Since(time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	families := gather(t, NewHistory(nil, days, opt))
+
+	family := families["greatriverenergy_shed_event"]
+	if family == nil {
+		t.Fatal("no greatriverenergy_shed_event samples")
+	}
+
+	type sample struct {
+		value float64
+		at    time.Time
+	}
+	series := make(map[string][]sample)
+	for _, m := range family.GetMetric() {
+		series[labelString(m)] = append(series[labelString(m)], sample{m.GetGauge().GetValue(), time.UnixMilli(m.GetTimestampMs())})
+	}
+
+	for labels, window := range map[string][2]time.Time{
+		`class="CI",program="Interruptible Irrigation"`: {time.Unix(1688418000, 0), time.Unix(1688432400, 0)},
+		`class="R",program="Cycled Air Conditioning"`:   {time.Unix(1688414400, 0), time.Unix(1688428800, 0)},
+		`class="CPP",program="Critical Peak Pricing"`:   {time.Unix(1688418000, 0), time.Unix(1688428800, 0)},
+	} {
+		samples := series[labels]
+		if len(samples) == 0 {
+			t.Errorf("no samples for {%s}", labels)
+			continue
+		}
+
+		first, last := samples[0], samples[len(samples)-1]
+		if first.value != 0 || !first.at.Equal(window[0].Add(-time.Minute)) {
+			t.Errorf("{%s} first sample = %+v, want 0 at %v", labels, first, window[0].Add(-time.Minute))
+		}
+		if last.value != 0 || !last.at.Equal(window[1].Add(time.Minute)) {
+			t.Errorf("{%s} last sample = %+v, want 0 at %v", labels, last, window[1].Add(time.Minute))
+		}
+
+		// one leading zero, one sample for each minute of the event, and one trailing zero
+		if want := int(window[1].Sub(window[0]).Minutes()) + 2; len(samples) != want {
+			t.Errorf("{%s} has %v samples, want %v", labels, len(samples), want)
+		}
+	}
+
+	if len(series) != 3 {
+		t.Errorf("expected 3 series, got %v", len(series))
+	}
+}
+
+func TestHistory_WriteTo(t *testing.T) {
+	server, opt := newTestServer(t)
+	server.SetHistory("CPP", []lmguidetest.HistoryRow{
+		{Date: "07/03/2023", Program: "Critical \"Peak\" Pricing", Start: "16:00", End: "19:00", Hours: "3"},
+	})
+
+	days := int(time.Since(time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	history := NewHistory(nil, days, opt)
+
+	var buf bytes.Buffer
+	n, err := history.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != int64(buf.Len()) {
+		t.Errorf("WriteTo() = %v, but wrote %v bytes", n, buf.Len())
+	}
+
+	var parser expfmt.TextParser
+	written, err := parser.TextToMetricFamilies(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo() wrote invalid text: %v", err)
+	}
+
+	// The same samples as Collect, in the same order for each series
+	type sample struct {
+		value float64
+		at    int64
+	}
+	samples := func(families map[string]*dto.MetricFamily) map[string][]sample {
+		series := make(map[string][]sample)
+		for _, m := range families["greatriverenergy_shed_event"].GetMetric() {
+			series[labelString(m)] = append(series[labelString(m)], sample{m.GetGauge().GetValue(), m.GetTimestampMs()})
+		}
+		return series
+	}
+	got, want := samples(written), samples(gather(t, history))
+	if len(got) != 3 {
+		t.Errorf("expected 3 series, got %v", len(got))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteTo() wrote %v series, Collect() gathered %v", len(got), len(want))
+	}
+}
+
+func TestHistory_Collect_DaylightSaving(t *testing.T) {
+	server, opt := newTestServer(t)
+	// November 5, 2023: the clocks go back from 02:00 to 01:00, so this event lasts 4 hours
+	server.SetHistory("RES", []lmguidetest.HistoryRow{
+		{Date: "11/05/2023", Program: "Cycled Air Conditioning", Start: "00:00", End: "03:00", Hours: "4.00"},
+	})
+
+	days := int(time.Since(time.Date(2023, 11, 4, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	families := gather(t, NewHistory(nil, days, opt))
+
+	var ones []time.Time
+	for _, m := range families["greatriverenergy_shed_event"].GetMetric() {
+		if labelString(m) == `class="R",program="Cycled Air Conditioning"` && m.GetGauge().GetValue() == 1 {
+			ones = append(ones, time.UnixMilli(m.GetTimestampMs()))
+		}
+	}
+
+	// One sample for each elapsed minute, including both passes through 01:00
+	if len(ones) != 4*60 {
+		t.Fatalf("got %v samples, want %v", len(ones), 4*60)
+	}
+	for i := 1; i < len(ones); i++ {
+		if d := ones[i].Sub(ones[i-1]); d != time.Minute {
+			t.Fatalf("samples %v and %v are %v apart", ones[i-1], ones[i], d)
+		}
+	}
+	if start := time.Date(2023, 11, 5, 5, 0, 0, 0, time.UTC); !ones[0].Equal(start) {
+		t.Errorf("first sample at %v, want %v", ones[0], start)
+	}
+}
+
+func TestHistory_Collect_WithClock(t *testing.T) {
+	_, opt := newTestServer(t)
+	// The day after the 07/03/2023 events
+	clock := greatriverenergy.FixedClock(time.Date(2023, 7, 4, 12, 0, 0, 0, time.UTC))
+	families := gather(t, NewHistory(nil, 2, opt, WithClock(clock)))
+
+	series := make(map[string]int)
+	for _, m := range families["greatriverenergy_shed_event"].GetMetric() {
+		series[labelString(m)]++
+	}
+	// 4 hours of samples, plus a leading and trailing zero
+	if got := series[`class="CI",program="Interruptible Irrigation"`]; got != 4*60+2 {
+		t.Errorf("got %v samples, want %v", got, 4*60+2)
+	}
+	if len(series) != 2 {
+		t.Errorf("expected 2 series, got %v", len(series))
+	}
+}
@@ -0,0 +1,116 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+// Option configures a Realtime or History collector.
+type Option func(*options)
+
+// The history types collected unless WithAllHistoryTypes is used
+var defaultHistoryTypes = []greatriverenergy.HistoryType{
+	greatriverenergy.HistoryTypeR,
+	greatriverenergy.HistoryTypeCI,
+	greatriverenergy.HistoryTypeCriticalPeakPricing,
+}
+
+type options struct {
+	clientOpts      []greatriverenergy.Option
+	maxConcurrency  int
+	allHistoryTypes bool
+	clock           greatriverenergy.Clock
+	schemas         []MetricSchema
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithClientOptions applies options to every greatriverenergy.Client the collector creates.
+func WithClientOptions(opts ...greatriverenergy.Option) Option {
+	return func(o *options) {
+		o.clientOpts = append(o.clientOpts, opts...)
+	}
+}
+
+// WithConcurrency limits the number of requests a collector makes to the site at once. Realtime defaults to making
+// all of its requests at once, however many types of history it collects, while History defaults to the
+// greatriverenergy.HistoryRangeOptions default.
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.maxConcurrency = n
+	}
+}
+
+// slots returns a channel with room for the number of requests Realtime may make at once, or nil if there is no limit
+func (o options) slots() chan struct{} {
+	if o.maxConcurrency < 1 {
+		return nil
+	}
+	return make(chan struct{}, o.maxConcurrency)
+}
+
+// WithAllHistoryTypes collects every type of history which the site's history form offers, rather than only
+// residential, commercial and industrial, and critical peak pricing history.
+func WithAllHistoryTypes() Option {
+	return func(o *options) {
+		o.allHistoryTypes = true
+	}
+}
+
+// WithClock makes the collector and its clients read the time from clock rather than greatriverenergy.SystemClock.
+func WithClock(clock greatriverenergy.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// now returns the time according to the configured clock
+func (o options) now() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+	return o.clock.Now()
+}
+
+// historyTypes returns the types of history to collect, falling back to the defaults if the site's types can't be
+// determined
+func (o options) historyTypes(ctx context.Context, client *greatriverenergy.Client) []greatriverenergy.HistoryType {
+	if !o.allHistoryTypes {
+		return defaultHistoryTypes
+	}
+
+	options, err := client.HistoryTypes(ctx)
+	if err != nil {
+		logFailure("HistoryTypes()", err)
+		return defaultHistoryTypes
+	}
+	historyTypes := make([]greatriverenergy.HistoryType, len(options))
+	for i, option := range options {
+		historyTypes[i] = option.Type
+	}
+	return historyTypes
+}
+
+// newClient returns a client with the configured options. Clients parse tolerantly, so that one unexpected value on a
+// page doesn't prevent exporting everything else, and retry transient failures, so that one dropped connection
+// doesn't either.
+func (o options) newClient(rt http.RoundTripper) *greatriverenergy.Client {
+	opts := []greatriverenergy.Option{
+		greatriverenergy.WithTolerantParsing(),
+		greatriverenergy.WithRetries(greatriverenergy.RetryPolicy{}),
+	}
+	if o.clock != nil {
+		opts = append(opts, greatriverenergy.WithClock(o.clock))
+	}
+	opts = append(opts, o.clientOpts...)
+	return greatriverenergy.NewClient(rt, opts...)
+}
@@ -0,0 +1,237 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+// The pages reported by greatriverenergy_last_success_timestamp_seconds. History is reported for each type, as labeled
+// by historyPageLabel.
+const (
+	schedulePage   = "Default.aspx"
+	shedCountsPage = "ShedCount.aspx"
+	historyPage    = "HistoryForm.aspx"
+)
+
+// PollIntervals controls how often Realtime retrieves each page in the background.
+type PollIntervals struct {
+	// The interval between retrievals of the schedule. Defaults to 1 minute.
+	Schedule time.Duration
+	// The interval between retrievals of the shed counts. Defaults to 15 minutes.
+	ShedCounts time.Duration
+	// The interval between retrievals of recent history. Defaults to 5 minutes.
+	History time.Duration
+}
+
+func (i PollIntervals) withDefaults() PollIntervals {
+	if i.Schedule <= 0 {
+		i.Schedule = time.Minute
+	}
+	if i.ShedCounts <= 0 {
+		i.ShedCounts = 15 * time.Minute
+	}
+	if i.History <= 0 {
+		i.History = 5 * time.Minute
+	}
+	return i
+}
+
+// snapshot holds the last successful result for each page
+type snapshot struct {
+	mu          sync.Mutex
+	polling     bool
+	schedule    *greatriverenergy.Schedule
+	shedCounts  *greatriverenergy.ShedCounts
+	histories   map[greatriverenergy.HistoryType]*greatriverenergy.History
+	lastSuccess map[string]time.Time
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{
+		histories:   make(map[greatriverenergy.HistoryType]*greatriverenergy.History),
+		lastSuccess: make(map[string]time.Time),
+	}
+}
+
+func (s *snapshot) isPolling() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polling
+}
+
+// get returns the current contents of the snapshot, which must not be modified
+func (s *snapshot) get() (*greatriverenergy.Schedule, *greatriverenergy.ShedCounts, map[greatriverenergy.HistoryType]*greatriverenergy.History, map[string]time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	histories := make(map[greatriverenergy.HistoryType]*greatriverenergy.History, len(s.histories))
+	for historyType, history := range s.histories {
+		histories[historyType] = history
+	}
+	lastSuccess := make(map[string]time.Time, len(s.lastSuccess))
+	for page, t := range s.lastSuccess {
+		lastSuccess[page] = t
+	}
+	return s.schedule, s.shedCounts, histories, lastSuccess
+}
+
+// update modifies the snapshot, recording a success for page
+func (s *snapshot) update(page string, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+	s.lastSuccess[page] = time.Now()
+}
+
+// Start retrieves each page in the background at the given intervals until ctx is done. Once started, Collect no
+// longer contacts the site, and instead reports the last successful result for each page, along with
+// greatriverenergy_last_success_timestamp_seconds to show how old it is.
+func (c Realtime) Start(ctx context.Context, intervals PollIntervals) {
+	intervals = intervals.withDefaults()
+
+	c.state.mu.Lock()
+	c.state.polling = true
+	c.state.mu.Unlock()
+
+	go poll(ctx, intervals.Schedule, c.refreshSchedule)
+	go poll(ctx, intervals.ShedCounts, c.refreshShedCounts)
+	go poll(ctx, intervals.History, c.refreshHistory)
+}
+
+// poll calls refresh immediately, and then at every interval until ctx is done
+func poll(ctx context.Context, interval time.Duration, refresh func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		refresh(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refresh retrieves every page at once, subject to the concurrency limit, and returns when they are all done
+func (c Realtime) refresh(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, refresh := range []func(context.Context){c.refreshSchedule, c.refreshShedCounts, c.refreshHistory} {
+		wg.Add(1)
+		go func(refresh func(context.Context)) {
+			defer wg.Done()
+			refresh(ctx)
+		}(refresh)
+	}
+	wg.Wait()
+}
+
+// limit calls fn once fewer than the configured number of requests are in progress, or immediately if there is no
+// limit
+func (c Realtime) limit(ctx context.Context, fn func() error) error {
+	if c.slots == nil {
+		return fn()
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.slots }()
+	return fn()
+}
+
+func (c Realtime) refreshSchedule(ctx context.Context) {
+	start := time.Now()
+	var schedule *greatriverenergy.Schedule
+	err := c.limit(ctx, func() (err error) {
+		start = time.Now()
+		schedule, err = c.client.Schedule(ctx)
+		return err
+	})
+	if err != nil {
+		c.health.observe(schedulePage, start, err, nil)
+		logFailure("Schedule()", err)
+		return
+	}
+	c.health.observe(schedulePage, start, nil, schedule.Warnings)
+	logWarnings("Schedule()", schedule.Warnings)
+	c.state.update(schedulePage, func() { c.state.schedule = schedule })
+}
+
+func (c Realtime) refreshShedCounts(ctx context.Context) {
+	start := time.Now()
+	var shedCounts *greatriverenergy.ShedCounts
+	err := c.limit(ctx, func() (err error) {
+		start = time.Now()
+		shedCounts, err = c.client.ShedCounts(ctx)
+		return err
+	})
+	if err != nil {
+		c.health.observe(shedCountsPage, start, err, nil)
+		logFailure("ShedCounts()", err)
+		return
+	}
+	c.health.observe(shedCountsPage, start, nil, shedCounts.Warnings)
+	logWarnings("ShedCounts()", shedCounts.Warnings)
+	c.state.update(shedCountsPage, func() { c.state.shedCounts = shedCounts })
+}
+
+// refreshHistory retrieves recent and upcoming history of each type at once, recording a success for each type which
+// was retrieved, so that one failing type does not hold back the others.
+func (c Realtime) refreshHistory(ctx context.Context) {
+	histories := c.fetchHistories(ctx, c.opts.now(), c.health)
+
+	c.state.mu.Lock()
+	defer c.state.mu.Unlock()
+	for historyType, history := range histories {
+		c.state.histories[historyType] = history
+		c.state.lastSuccess[historyPageLabel(historyType)] = time.Now()
+	}
+}
+
+// fetchHistories retrieves history of each type at once, from a week before now until two days after. It returns
+// whichever types were retrieved. Each retrieval is observed by h, unless h is nil.
+func (c Realtime) fetchHistories(ctx context.Context, now time.Time, h *health) map[greatriverenergy.HistoryType]*greatriverenergy.History {
+	start := now.AddDate(0, 0, -7)
+	end := now.AddDate(0, 0, 2)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	histories := make(map[greatriverenergy.HistoryType]*greatriverenergy.History)
+	for _, historyType := range c.opts.historyTypes(ctx, c.client) {
+		wg.Add(1)
+		go func(historyType greatriverenergy.HistoryType) {
+			defer wg.Done()
+
+			fetchStart := time.Now()
+			var history *greatriverenergy.History
+			err := c.limit(ctx, func() (err error) {
+				fetchStart = time.Now()
+				history, err = c.client.History(ctx, historyType, start, end)
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if h != nil {
+					h.observe(historyPageLabel(historyType), fetchStart, err, nil)
+				}
+				logFailure(fmt.Sprintf("History(%q)", historyType), err)
+				return
+			}
+			if h != nil {
+				h.observe(historyPageLabel(historyType), fetchStart, nil, history.Warnings)
+			}
+			logWarnings(fmt.Sprintf("History(%q)", historyType), history.Warnings)
+			histories[historyType] = history
+		}(historyType)
+	}
+	wg.Wait()
+	return histories
+}
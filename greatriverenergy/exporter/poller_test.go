@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+// countingTransport counts the requests passing through it
+type countingTransport struct {
+	requests atomic.Int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRealtime_Start(t *testing.T) {
+	_, opt := newTestServer(t)
+	rt := &countingTransport{}
+	realtime := NewRealtime(rt, opt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	realtime.Start(ctx, PollIntervals{Schedule: time.Hour, ShedCounts: time.Hour, History: time.Hour})
+
+	// Wait for every page, and every default type of history, to be retrieved
+	deadline := time.Now().Add(5 * time.Second)
+	var families = gather(t, realtime)
+	for len(gaugeValues(families["greatriverenergy_last_success_timestamp_seconds"])) < 2+len(defaultHistoryTypes) {
+		if time.Now().After(deadline) {
+			t.Fatalf("last_success_timestamp_seconds = %v", gaugeValues(families["greatriverenergy_last_success_timestamp_seconds"]))
+		}
+		time.Sleep(10 * time.Millisecond)
+		families = gather(t, realtime)
+	}
+
+	for page, ts := range gaugeValues(families["greatriverenergy_last_success_timestamp_seconds"]) {
+		if age := time.Since(time.Unix(int64(ts), 0)); age < 0 || age > time.Minute {
+			t.Errorf("last_success_timestamp_seconds{%s} = %v", page, ts)
+		}
+	}
+	if got := gaugeValues(families["greatriverenergy_conservation_gauge"]); got[""] != 1 {
+		t.Errorf("conservation_gauge = %v, want 1", got)
+	}
+	if len(families["greatriverenergy_shed_count"].GetMetric()) != 13 {
+		t.Error("expected 13 shed_count samples")
+	}
+
+	// Collecting again should be served from the snapshot
+	requests := rt.requests.Load()
+	gather(t, realtime)
+	if got := rt.requests.Load(); got != requests {
+		t.Errorf("Collect() made %v requests while polling", got-requests)
+	}
+}
+
+func TestRealtime_Start_HistoryFailure(t *testing.T) {
+	server, opt := newTestServer(t)
+	// The site no longer offers commercial and industrial history
+	server.SetHistoryOptions([]lmguidetest.HistoryOption{{Code: "RES", Label: "Residential"}})
+	realtime := NewRealtime(nil, opt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	realtime.Start(ctx, PollIntervals{Schedule: time.Hour, ShedCounts: time.Hour, History: time.Hour})
+
+	deadline := time.Now().Add(5 * time.Second)
+	var families = gather(t, realtime)
+	for len(gaugeValues(families["greatriverenergy_scrape_success"])) < 2+len(defaultHistoryTypes) {
+		if time.Now().After(deadline) {
+			t.Fatalf("scrape_success = %v", gaugeValues(families["greatriverenergy_scrape_success"]))
+		}
+		time.Sleep(10 * time.Millisecond)
+		families = gather(t, realtime)
+	}
+
+	// Residential history is still fresh
+	lastSuccess := gaugeValues(families["greatriverenergy_last_success_timestamp_seconds"])
+	if _, ok := lastSuccess[`page="HistoryForm.aspx/RES"`]; !ok {
+		t.Errorf("last_success_timestamp_seconds = %v, want a sample for HistoryForm.aspx/RES", lastSuccess)
+	}
+	if _, ok := lastSuccess[`page="HistoryForm.aspx/CI"`]; ok {
+		t.Errorf("last_success_timestamp_seconds = %v, want no sample for HistoryForm.aspx/CI", lastSuccess)
+	}
+}
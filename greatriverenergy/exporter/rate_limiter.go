@@ -0,0 +1,47 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+// RateLimiter reports statistics from a greatriverenergy.RateLimiter. Pass the limiter to the collectors with
+// WithClientOptions(greatriverenergy.WithRateLimiter(...)), and register this to export its statistics.
+type RateLimiter struct {
+	limiter *greatriverenergy.RateLimiter
+
+	queued  *prometheus.Desc
+	delayed *prometheus.Desc
+	waited  *prometheus.Desc
+}
+
+func NewRateLimiter(limiter *greatriverenergy.RateLimiter) RateLimiter {
+	return RateLimiter{
+		limiter: limiter,
+
+		queued: prometheus.NewDesc("greatriverenergy_rate_limiter_queued_requests",
+			"The number of requests to the site currently waiting on the rate limiter", nil, nil,
+		),
+		delayed: prometheus.NewDesc("greatriverenergy_rate_limiter_delayed_requests_total",
+			"The number of requests to the site which have had to wait on the rate limiter", nil, nil,
+		),
+		waited: prometheus.NewDesc("greatriverenergy_rate_limiter_wait_seconds_total",
+			"The total time requests to the site have spent waiting on the rate limiter", nil, nil,
+		),
+	}
+}
+
+func (c RateLimiter) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.queued
+	descs <- c.delayed
+	descs <- c.waited
+}
+
+func (c RateLimiter) Collect(metrics chan<- prometheus.Metric) {
+	stats := c.limiter.Stats()
+	metrics <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(stats.Queued))
+	metrics <- prometheus.MustNewConstMetric(c.delayed, prometheus.CounterValue, float64(stats.Delayed))
+	metrics <- prometheus.MustNewConstMetric(c.waited, prometheus.CounterValue, stats.Waited.Seconds())
+}
+
+var _ prometheus.Collector = &RateLimiter{}
@@ -0,0 +1,27 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+func TestRateLimiter(t *testing.T) {
+	_, opt := newTestServer(t)
+	limiter := greatriverenergy.NewRateLimiter(100, 1)
+
+	// Every client created by the collector shares the limiter
+	gather(t, NewRealtime(nil, opt, WithClientOptions(greatriverenergy.WithRateLimiter(limiter))))
+
+	families := gather(t, NewRateLimiter(limiter))
+	if got := gaugeValues(families["greatriverenergy_rate_limiter_queued_requests"]); got[""] != 0 {
+		t.Errorf("rate_limiter_queued_requests = %v, want 0", got)
+	}
+	// Realtime makes six requests, mostly at once, so several had to wait
+	if got := gaugeValues(families["greatriverenergy_rate_limiter_delayed_requests_total"]); got[""] < 3 {
+		t.Errorf("rate_limiter_delayed_requests_total = %v, want at least 3", got)
+	}
+	if got := gaugeValues(families["greatriverenergy_rate_limiter_wait_seconds_total"]); got[""] <= 0 {
+		t.Errorf("rate_limiter_wait_seconds_total = %v, want more than 0", got)
+	}
+}
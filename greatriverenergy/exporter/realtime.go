@@ -2,8 +2,9 @@ package exporter
 
 import (
 	"context"
-	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -12,12 +13,25 @@ import (
 
 type Realtime struct {
 	client *greatriverenergy.Client
-	rt     http.RoundTripper
+	opts   options
+	state  *snapshot
+	health *health
+	ctx    context.Context
+	// If set, the moment at which to evaluate events
+	asOf time.Time
+	// Holds a value for each request in progress
+	slots chan struct{}
+
+	lastSuccess *prometheus.Desc
 
 	conservationStatus *prometheus.Desc
 	shedLikelihood     *prometheus.Desc
 	scheduleUpdated    *prometheus.Desc
 
+	scheduleLikelihood *prometheus.Desc
+	expectedStart      *prometheus.Desc
+	expectedEnd        *prometheus.Desc
+
 	shedCount        *prometheus.Desc
 	shedCountResetOn *prometheus.Desc
 
@@ -26,11 +40,35 @@ type Realtime struct {
 	timeUntilShedEnd   *prometheus.Desc
 }
 
-func NewRealtime(rt http.RoundTripper) Realtime {
+// scheduledFor returns whether a program in the schedule belongs to a type of history. Critical peak pricing is told
+// apart by program, since it is scheduled under the class of the members it is offered to, and other programs by class.
+func scheduledFor(historyType greatriverenergy.HistoryType, program greatriverenergy.ProgramSchedule) bool {
+	criticalPeakPricing := strings.EqualFold(program.ProgramType, "Critical Peak Pricing")
+	switch historyType {
+	case greatriverenergy.HistoryTypeR:
+		return program.Class == greatriverenergy.ClassR && !criticalPeakPricing
+	case greatriverenergy.HistoryTypeCI:
+		return program.Class == greatriverenergy.ClassCI && !criticalPeakPricing
+	case greatriverenergy.HistoryTypeCriticalPeakPricing:
+		return criticalPeakPricing
+	default:
+		return false
+	}
+}
+
+func NewRealtime(rt http.RoundTripper, opts ...Option) Realtime {
+	o := newOptions(opts)
 	return Realtime{
-		client: greatriverenergy.NewClient(rt),
-		rt:     rt,
+		client: o.newClient(rt),
+		opts:   o,
+		state:  newSnapshot(),
+		health: newHealth(),
+		slots:  o.slots(),
 
+		lastSuccess: prometheus.NewDesc("greatriverenergy_last_success_timestamp_seconds",
+			"The time at which a page was last retrieved successfully",
+			[]string{"page"}, nil,
+		),
 		conservationStatus: prometheus.NewDesc("greatriverenergy_conservation_gauge",
 			"An indicator of electric transmission system load versus capacity. 1 = Normal, 2 = Elevated, 3 = Peak, 4 = Critical",
 			nil, nil,
@@ -41,6 +79,19 @@ func NewRealtime(rt http.RoundTripper) Realtime {
 		),
 		scheduleUpdated: prometheus.NewDesc("greatriverenergy_scheduled_updated", "The timestamp at which the schedule was last updated", nil, nil),
 
+		scheduleLikelihood: prometheus.NewDesc("greatriverenergy_schedule_likelihood",
+			"An indicator of the likelihood of using a load shedding program on a day. 1 = Unlikely, 2 = Possible, 3 = Likely, 4 = Scheduled",
+			[]string{"class", "program", "forecast_date", "window"}, nil,
+		),
+		expectedStart: prometheus.NewDesc("greatriverenergy_schedule_expected_start_timestamp_seconds",
+			"The time at which a load shedding program is expected to start on a day",
+			[]string{"class", "program", "forecast_date", "window"}, nil,
+		),
+		expectedEnd: prometheus.NewDesc("greatriverenergy_schedule_expected_end_timestamp_seconds",
+			"The time at which a load shedding program is expected to end on a day",
+			[]string{"class", "program", "forecast_date", "window"}, nil,
+		),
+
 		shedCount: prometheus.NewDesc("greatriverenergy_shed_count",
 			"The number of times a load shedding event occurred",
 			[]string{"program"}, nil,
@@ -61,10 +112,24 @@ func NewRealtime(rt http.RoundTripper) Realtime {
 	}
 }
 
+// AsOf returns a copy of the collector which reports ongoing events, and the time until events start and end, as they
+// would have been at t. History around t is retrieved for the purpose, and scheduled events are considered only if the
+// schedule had been updated by t. Everything else is reported from the last successful retrievals, since the site
+// doesn't say what it was. The copy neither refreshes those retrievals nor reports on its own in the health metrics.
+func (c Realtime) AsOf(t time.Time) Realtime {
+	c.asOf = t
+	return c
+}
+
 func (c Realtime) Describe(descs chan<- *prometheus.Desc) {
+	c.health.Describe(descs)
+	descs <- c.lastSuccess
 	descs <- c.conservationStatus
 	descs <- c.shedLikelihood
 	descs <- c.scheduleUpdated
+	descs <- c.scheduleLikelihood
+	descs <- c.expectedStart
+	descs <- c.expectedEnd
 	descs <- c.shedCount
 	descs <- c.shedCountResetOn
 	descs <- c.ongoingShedEvent
@@ -73,61 +138,98 @@ func (c Realtime) Describe(descs chan<- *prometheus.Desc) {
 }
 
 func (c Realtime) Collect(metrics chan<- prometheus.Metric) {
-	ctx := context.Background()
+	ctx := collectContext(c.ctx)
+
+	// Without a poller, retrieve everything now, unless this is a look at another moment which shouldn't disturb the
+	// snapshot or the health of the regular retrievals
+	if !c.state.isPolling() && c.asOf.IsZero() {
+		c.refresh(ctx)
+	}
+
+	c.health.Collect(metrics)
+
+	schedule, shedCounts, histories, lastSuccess := c.state.get()
+	now := c.opts.now()
+	if !c.asOf.IsZero() {
+		now = c.asOf
+		histories = c.fetchHistories(ctx, now, nil)
+	}
+	for page, t := range lastSuccess {
+		metrics <- prometheus.MustNewConstMetric(c.lastSuccess, prometheus.GaugeValue, float64(t.UnixNano())/1e9, page)
+	}
 
 	var scheduleEvents []greatriverenergy.ProgramSchedule
-	if schedule, err := c.client.Schedule(ctx); err != nil {
-		log.Printf("Schedule() failed: %v", err)
-	} else {
-		metrics <- prometheus.MustNewConstMetric(c.conservationStatus, prometheus.GaugeValue, float64(schedule.ConservationGauge))
-		metrics <- prometheus.MustNewConstMetric(c.scheduleUpdated, prometheus.GaugeValue, float64(schedule.LastUpdated.Unix()))
+	if schedule != nil {
+		// Omit anything which could not be parsed
+		if schedule.ConservationGauge != 0 {
+			metrics <- prometheus.MustNewConstMetric(c.conservationStatus, prometheus.GaugeValue, float64(schedule.ConservationGauge))
+		}
+		if !schedule.LastUpdated.IsZero() {
+			metrics <- prometheus.MustNewConstMetric(c.scheduleUpdated, prometheus.GaugeValue, float64(schedule.LastUpdated.Unix()))
+		}
 
 		for when, programs := range map[string][]greatriverenergy.ProgramSchedule{
 			"today":    schedule.Today,
 			"next_day": schedule.NextDay,
 		} {
-			scheduleEvents = append(scheduleEvents, programs...)
+			// Don't consider a schedule from after the moment being evaluated
+			if c.asOf.IsZero() || !schedule.LastUpdated.IsZero() && !schedule.LastUpdated.After(c.asOf) {
+				scheduleEvents = append(scheduleEvents, programs...)
+			}
+
+			if c.opts.schema(MetricSchemaV2) {
+				c.collectScheduleV2(metrics, programs)
+			}
+			if !c.opts.schema(MetricSchemaV1) {
+				continue
+			}
+
+			// A program may be listed more than once for a day, for several windows or under several classes, but only
+			// one sample may be reported for it, so report its greatest likelihood
+			likelihood := make(map[string]greatriverenergy.Probability)
 			for _, program := range programs {
-				metrics <- prometheus.MustNewConstMetric(c.shedLikelihood, prometheus.GaugeValue, float64(program.Probability), program.ProgramType, when)
+				if program.Probability > likelihood[program.ProgramType] {
+					likelihood[program.ProgramType] = program.Probability
+				}
+			}
+			for program, probability := range likelihood {
+				metrics <- prometheus.MustNewConstMetric(c.shedLikelihood, prometheus.GaugeValue, float64(probability), program, when)
 			}
 		}
 	}
 
-	if shedCounts, err := c.client.ShedCounts(ctx); err != nil {
-		log.Printf("ShedCounts() failed: %v", err)
-	} else {
+	if shedCounts != nil {
 		for program, count := range shedCounts.Table {
 			metrics <- prometheus.MustNewConstMetric(c.shedCount, prometheus.CounterValue, float64(count), program)
 		}
-		metrics <- prometheus.MustNewConstMetric(c.shedCountResetOn, prometheus.GaugeValue, float64(shedCounts.LastResetOn.Unix()))
+		if !shedCounts.LastResetOn.IsZero() {
+			metrics <- prometheus.MustNewConstMetric(c.shedCountResetOn, prometheus.GaugeValue, float64(shedCounts.LastResetOn.Unix()))
+		}
 	}
 
-	now := time.Now()
-	start := now.AddDate(0, 0, -7)
-	end := time.Now().AddDate(0, 0, 2)
-	for historyType, class := range map[greatriverenergy.HistoryType]string{
-		greatriverenergy.HistoryTypeR:  "R",
-		greatriverenergy.HistoryTypeCI: "CI",
-	} {
-		// Use a new client to get this history, since history retrieval is stateful
-		history, err := greatriverenergy.NewClient(c.rt).History(ctx, historyType, start, end)
-		if err != nil {
-			log.Printf("History(%q) failed: %v", historyType, err)
-			continue
-		}
+	historyTypes := make([]greatriverenergy.HistoryType, 0, len(histories))
+	for historyType := range histories {
+		historyTypes = append(historyTypes, historyType)
+	}
+	sort.Slice(historyTypes, func(i, j int) bool { return historyTypes[i] < historyTypes[j] })
+	for _, historyType := range historyTypes {
+		class := historyType.Class()
+		history := histories[historyType]
 
-		// Merge in any scheduled events
+		// Synthesize a record for each scheduled event
+		var scheduled []greatriverenergy.HistoryEvent
 		for _, program := range scheduleEvents {
 			// Ignore any events not for this class or not scheduled
 			if program.Probability != greatriverenergy.ProbabilityScheduled {
 				continue
 			}
-			if (class == "R" && program.Class != greatriverenergy.ClassR) || (class == "CI" && program.Class != greatriverenergy.ClassCI) {
+			if !scheduledFor(historyType, program) {
 				continue
 			}
 
-			// Synthesize a record
-			history.Events = append(history.Events, greatriverenergy.HistoryEvent{
+			scheduled = append(scheduled, greatriverenergy.HistoryEvent{
+				HistoryType: historyType,
+				Class:       class,
 				ProgramName: program.ProgramType,
 				Hours:       program.ExpectedEndTime.Sub(program.ExpectedStartTime).Hours(),
 				StartAt:     program.ExpectedStartTime,
@@ -135,22 +237,19 @@ func (c Realtime) Collect(metrics chan<- prometheus.Metric) {
 			})
 		}
 
+		// Merge them with the history, so that an event which appears in both is counted once
+		events := greatriverenergy.NewEventSet(history.Events...).Union(greatriverenergy.NewEventSet(scheduled...))
+
 		programOngoing := make(map[string]int)
 		programStart := make(map[string]float64)
 		programEnd := make(map[string]float64)
 
-		for _, event := range history.Events {
+		for _, event := range events.Events() {
 			// Ensure this program exists in the ongoing map
 			programOngoing[event.ProgramName] = programOngoing[event.ProgramName]
 
-			// Is this event ongoing?
-			if now.After(event.StartAt) && event.EndAt.After(now) {
-				// Set the ongoing map to 1 and time until to 0
-				programOngoing[event.ProgramName] = 1
-				programStart[event.ProgramName] = 0
-
-				// If not, will it start soon?
-			} else if now.Before(event.StartAt) {
+			// Will this event start soon?
+			if now.Before(event.StartAt) {
 				// Determine when
 				seconds := event.StartAt.Sub(now).Seconds()
 				// Store it if we have no record, or if the record indicates a longer interval
@@ -169,6 +268,12 @@ func (c Realtime) Collect(metrics chan<- prometheus.Metric) {
 			}
 		}
 
+		// Set the ongoing map to 1 and time until to 0 for any events in progress
+		for _, event := range events.ActiveAt(now) {
+			programOngoing[event.ProgramName] = 1
+			programStart[event.ProgramName] = 0
+		}
+
 		for program, ongoing := range programOngoing {
 			metrics <- prometheus.NewMetricWithTimestamp(now, prometheus.MustNewConstMetric(c.ongoingShedEvent, prometheus.GaugeValue, float64(ongoing), class, program))
 		}
@@ -0,0 +1,382 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+func TestRealtime_Collect(t *testing.T) {
+	_, opt := newTestServer(t)
+	families := gather(t, NewRealtime(nil, opt))
+
+	if got := gaugeValues(families["greatriverenergy_conservation_gauge"]); got[""] != 1 {
+		t.Errorf("conservation_gauge = %v, want 1", got)
+	}
+
+	wantUpdated := time.Date(2023, 7, 8, 14, 30, 0, 0, time.UTC)
+	if got := gaugeValues(families["greatriverenergy_scheduled_updated"]); got[""] != float64(wantUpdated.Unix()) {
+		t.Errorf("scheduled_updated = %v, want %v", got, wantUpdated.Unix())
+	}
+
+	likelihood := gaugeValues(families["greatriverenergy_shed_likelihood"])
+	for labels, want := range map[string]float64{
+		`program="Interruptible Irrigation",when="today"`:       1,
+		`program="Interruptible Irrigation",when="next_day"`:    2,
+		`program="Interruptible Water Heating",when="next_day"`: 2,
+	} {
+		if got, ok := likelihood[labels]; !ok || got != want {
+			t.Errorf("shed_likelihood{%s} = %v, want %v", labels, got, want)
+		}
+	}
+	if len(likelihood) != 10 {
+		t.Errorf("expected 10 shed_likelihood samples, got %v", len(likelihood))
+	}
+
+	counts := gaugeValues(families["greatriverenergy_shed_count"])
+	if got := counts[`program="Interruptible Water Heating"`]; got != 300 {
+		t.Errorf("shed_count{program=\"Interruptible Water Heating\"} = %v, want 300", got)
+	}
+	if len(counts) != 13 {
+		t.Errorf("expected 13 shed_count samples, got %v", len(counts))
+	}
+
+	wantReset := time.Date(2014, 1, 14, 6, 0, 0, 0, time.UTC)
+	if got := gaugeValues(families["greatriverenergy_shed_count_reset_on"]); got[""] != float64(wantReset.Unix()) {
+		t.Errorf("shed_count_reset_on = %v, want %v", got, wantReset.Unix())
+	}
+
+	success := gaugeValues(families["greatriverenergy_scrape_success"])
+	for _, page := range []string{"Default.aspx", "ShedCount.aspx", "HistoryForm.aspx/RES", "HistoryForm.aspx/CI", "HistoryForm.aspx/CPP"} {
+		if got, ok := success[`page="`+page+`"`]; !ok || got != 1 {
+			t.Errorf("scrape_success{page=%q} = %v, want 1", page, got)
+		}
+	}
+	if len(families["greatriverenergy_scrape_duration_seconds"].GetMetric()) != 5 {
+		t.Errorf("expected 5 scrape_duration_seconds samples")
+	}
+	if _, ok := families["greatriverenergy_parse_errors_total"]; ok {
+		t.Errorf("parse_errors_total = %v, want nothing", gaugeValues(families["greatriverenergy_parse_errors_total"]))
+	}
+}
+
+func TestRealtime_Collect_Failure(t *testing.T) {
+	server, opt := newTestServer(t)
+	server.Close()
+
+	families := gather(t, NewRealtime(nil, opt))
+
+	success := gaugeValues(families["greatriverenergy_scrape_success"])
+	if len(success) != 5 {
+		t.Errorf("expected 5 scrape_success samples, got %v", success)
+	}
+	for labels, got := range success {
+		if got != 0 {
+			t.Errorf("scrape_success{%s} = %v, want 0", labels, got)
+		}
+	}
+	if _, ok := families["greatriverenergy_shed_count"]; ok {
+		t.Error("shed_count should be omitted when the site is unavailable")
+	}
+}
+
+func TestRealtime_Collect_UnknownValues(t *testing.T) {
+	server, opt := newTestServer(t)
+	page := bytes.Replace(lmguidetest.Fixture("Default.aspx"), []byte("images/gauge1.jpg"), []byte("images/gauge5.jpg"), 1)
+	page = bytes.Replace(page, []byte("<td>Possible</td><td>03:00 PM - 07:00 PM</td>"), []byte("<td>Imminent</td><td>03:00 PM - 07:00 PM</td>"), 1)
+	server.SetPage("Default.aspx", page)
+
+	// Collect twice, since each retrieval finds the same values
+	c := NewRealtime(nil, opt)
+	gather(t, c)
+	families := gather(t, c)
+
+	if _, ok := families["greatriverenergy_conservation_gauge"]; ok {
+		t.Error("conservation_gauge should be omitted when the gauge is unrecognized")
+	}
+
+	// Every other program should still be reported
+	likelihood := gaugeValues(families["greatriverenergy_shed_likelihood"])
+	if _, ok := likelihood[`program="Interruptible Irrigation",when="next_day"`]; ok {
+		t.Error("shed_likelihood should be omitted for an unrecognized probability")
+	}
+	if len(likelihood) != 9 {
+		t.Errorf("expected 9 shed_likelihood samples, got %v", len(likelihood))
+	}
+	if len(families["greatriverenergy_shed_count"].GetMetric()) != 13 {
+		t.Error("expected shed counts to be unaffected")
+	}
+
+	parseWarnings := gaugeValues(families["greatriverenergy_parse_warnings"])
+	for _, labels := range []string{`page="Default.aspx",reason="conservation_gauge"`, `page="Default.aspx",reason="probability"`} {
+		if got := parseWarnings[labels]; got != 1 {
+			t.Errorf("parse_warnings{%s} = %v, want 1", labels, got)
+		}
+	}
+	if _, ok := families["greatriverenergy_parse_errors_total"]; ok {
+		t.Errorf("parse_errors_total = %v, want nothing", gaugeValues(families["greatriverenergy_parse_errors_total"]))
+	}
+	if got := gaugeValues(families["greatriverenergy_scrape_success"])[`page="Default.aspx"`]; got != 1 {
+		t.Errorf("scrape_success{page=\"Default.aspx\"} = %v, want 1", got)
+	}
+
+	// Once the values are recognized, the warnings are cleared
+	server.SetPage("Default.aspx", lmguidetest.Fixture("Default.aspx"))
+	families = gather(t, c)
+	if _, ok := families["greatriverenergy_parse_warnings"]; ok {
+		t.Errorf("parse_warnings = %v, want nothing", gaugeValues(families["greatriverenergy_parse_warnings"]))
+	}
+}
+
+func TestRealtime_Collect_ParseErrors(t *testing.T) {
+	server, opt := newTestServer(t)
+	server.SetPage("ShedCount.aspx", []byte("<html><body><form id=\"form1\"></form></body></html>"))
+	// A history form which offers nothing can't be submitted
+	server.SetHistoryOptions([]lmguidetest.HistoryOption{{Code: "", Label: "Select a program"}})
+
+	c := NewRealtime(nil, opt)
+	gather(t, c)
+	families := gather(t, c)
+
+	// Each retrieval which could not be parsed is counted
+	label := `page="HistoryForm.aspx/RES",reason="history_types"`
+	if got := gaugeValues(families["greatriverenergy_parse_errors_total"])[label]; got != 2 {
+		t.Errorf("parse_errors_total = %v, want 2 for {%s}", gaugeValues(families["greatriverenergy_parse_errors_total"]), label)
+	}
+	if got := gaugeValues(families["greatriverenergy_scrape_success"])[`page="HistoryForm.aspx/RES"`]; got != 0 {
+		t.Errorf("scrape_success{page=\"HistoryForm.aspx/RES\"} = %v, want 0", got)
+	}
+
+	// A page missing its shed counts is only a warning, since the reset date is reported as far as possible
+	parseWarnings := gaugeValues(families["greatriverenergy_parse_warnings"])
+	for _, labels := range []string{`page="ShedCount.aspx",reason="shed_counts_table"`, `page="ShedCount.aspx",reason="reset_date"`} {
+		if got := parseWarnings[labels]; got != 1 {
+			t.Errorf("parse_warnings{%s} = %v, want 1", labels, got)
+		}
+	}
+	if got := gaugeValues(families["greatriverenergy_scrape_success"])[`page="ShedCount.aspx"`]; got != 1 {
+		t.Errorf("scrape_success{page=\"ShedCount.aspx\"} = %v, want 1", got)
+	}
+}
+
+// inFlightTransport delays each request, tracking the most requests in progress at once
+type inFlightTransport struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (t *inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.inFlight++
+	if t.inFlight > t.max {
+		t.max = t.inFlight
+	}
+	t.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	resp, err := http.DefaultTransport.RoundTrip(req)
+
+	t.mu.Lock()
+	t.inFlight--
+	t.mu.Unlock()
+	return resp, err
+}
+
+func TestRealtime_Collect_Concurrency(t *testing.T) {
+	for _, test := range []struct {
+		opts []Option
+		want int
+	}{
+		{nil, 5},
+		{[]Option{WithConcurrency(1)}, 1},
+		{[]Option{WithConcurrency(2)}, 2},
+	} {
+		_, opt := newTestServer(t)
+		rt := &inFlightTransport{}
+		families := gather(t, NewRealtime(rt, append(test.opts, opt)...))
+
+		if rt.max != test.want {
+			t.Errorf("%v requests in progress at once, want %v", rt.max, test.want)
+		}
+		for labels, got := range gaugeValues(families["greatriverenergy_scrape_success"]) {
+			if got != 1 {
+				t.Errorf("scrape_success{%s} = %v, want 1", labels, got)
+			}
+		}
+	}
+}
+
+func TestRealtime_Collect_Concurrency_AllHistoryTypes(t *testing.T) {
+	server, opt := newTestServer(t)
+	var options []lmguidetest.HistoryOption
+	for i := 1; i <= 6; i++ {
+		options = append(options, lmguidetest.HistoryOption{Code: fmt.Sprintf("T%v", i), Label: fmt.Sprintf("Type %v", i)})
+	}
+	server.SetHistoryOptions(options)
+
+	// Every type of history is retrieved at once, not only as many as are collected by default
+	rt := &inFlightTransport{}
+	gather(t, NewRealtime(rt, WithAllHistoryTypes(), opt))
+	if rt.max < len(options) {
+		t.Errorf("%v requests in progress at once, want at least %v", rt.max, len(options))
+	}
+}
+
+func TestRealtime_Collect_AllHistoryTypes(t *testing.T) {
+	server, opt := newTestServer(t)
+	server.SetHistoryOptions(append(lmguidetest.DefaultHistoryOptions, lmguidetest.HistoryOption{Code: "EV", Label: "Electric Vehicles"}))
+
+	families := gather(t, NewRealtime(nil, opt, WithAllHistoryTypes()))
+
+	success := gaugeValues(families["greatriverenergy_scrape_success"])
+	for _, code := range []string{"RES", "CI", "CPP", "PA", "EV"} {
+		if got, ok := success[`page="HistoryForm.aspx/`+code+`"`]; !ok || got != 1 {
+			t.Errorf("scrape_success{page=\"HistoryForm.aspx/%s\"} = %v, want 1", code, got)
+		}
+	}
+}
+
+func TestRealtime_Collect_CriticalPeakPricing(t *testing.T) {
+	server, opt := newTestServer(t)
+	// Critical peak pricing is offered to residential members, so it is scheduled under that class
+	row := []byte("<tr class=\"BodyText_noSpaces\">\n\t\t<td>CI</td><td>C&amp;I Interruptible Metered</td>")
+	cpp := []byte("<tr class=\"BodyText_noSpaces\">\n\t\t<td>Residential</td><td>Critical peak pricing</td><td>Scheduled</td><td>04:00 PM - 07:00 PM</td>\n\t</tr>")
+	server.SetPage("Default.aspx", bytes.Replace(lmguidetest.Fixture("Default.aspx"), row, append(cpp, row...), 1))
+
+	families := gather(t, NewRealtime(nil, opt))
+
+	if got := gaugeValues(families["greatriverenergy_shed_likelihood"])[`program="Critical peak pricing",when="today"`]; got != 4 {
+		t.Errorf("shed_likelihood{program=\"Critical peak pricing\",when=\"today\"} = %v, want 4", got)
+	}
+	// The scheduled event is merged into the CPP history by its program, which is otherwise empty for these dates
+	if _, ok := gaugeValues(families["greatriverenergy_ongoing_shed_event"])[`class="CPP",program="Critical peak pricing"`]; !ok {
+		t.Errorf("ongoing_shed_event = %v, want a CPP sample", gaugeValues(families["greatriverenergy_ongoing_shed_event"]))
+	}
+	// and only into the CPP history
+	if _, ok := gaugeValues(families["greatriverenergy_ongoing_shed_event"])[`class="R",program="Critical peak pricing"`]; ok {
+		t.Errorf("ongoing_shed_event = %v, want no residential sample", gaugeValues(families["greatriverenergy_ongoing_shed_event"]))
+	}
+}
+
+func TestRealtime_Collect_Overnight(t *testing.T) {
+	server, opt := newTestServer(t)
+	page := lmguidetest.Fixture("Default.aspx")
+	// Move the schedule into the future, so the event is yet to start
+	page = bytes.Replace(page, []byte("Jul  8, 2023 - 11:05 AM"), []byte("Jul  8, 2099 - 11:05 AM"), 1)
+	page = bytes.Replace(page, []byte("<td>Possible</td><td>03:00 PM - 07:00 PM</td>"), []byte("<td>Scheduled</td><td>08:00 PM - 02:00 AM</td>"), 1)
+	server.SetPage("Default.aspx", page)
+
+	families := gather(t, NewRealtime(nil, opt))
+
+	const labels = `class="CI",program="Interruptible Irrigation"`
+	start, ok := gaugeValues(families["greatriverenergy_time_until_shed_start"])[labels]
+	if !ok {
+		t.Fatalf("time_until_shed_start = %v, want an irrigation sample", gaugeValues(families["greatriverenergy_time_until_shed_start"]))
+	}
+	end := gaugeValues(families["greatriverenergy_time_until_shed_end"])[labels]
+	if got := end - start; got != 6*60*60 {
+		t.Errorf("time_until_shed_end - time_until_shed_start = %v, want 6h", got)
+	}
+	if got := gaugeValues(families["greatriverenergy_ongoing_shed_event"])[labels]; got != 0 {
+		t.Errorf("ongoing_shed_event = %v, want 0", got)
+	}
+}
+
+func TestRealtime_WithClock(t *testing.T) {
+	_, opt := newTestServer(t)
+	// During the 07/03/2023 events
+	clock := greatriverenergy.FixedClock(time.Unix(1688421600, 0))
+	families := gather(t, NewRealtime(nil, opt, WithClock(clock)))
+
+	const irrigation = `class="CI",program="Interruptible Irrigation"`
+	if got := gaugeValues(families["greatriverenergy_ongoing_shed_event"])[irrigation]; got != 1 {
+		t.Errorf("ongoing_shed_event{%s} = %v, want 1", irrigation, got)
+	}
+	if got := gaugeValues(families["greatriverenergy_time_until_shed_end"])[irrigation]; got != 3*60*60 {
+		t.Errorf("time_until_shed_end{%s} = %v, want 3h", irrigation, got)
+	}
+}
+
+func TestRealtime_AsOf(t *testing.T) {
+	_, opt := newTestServer(t)
+	c := NewRealtime(nil, opt)
+	// Retrieve the schedule, which is reported as it is now
+	gather(t, c)
+
+	const irrigation = `class="CI",program="Interruptible Irrigation"`
+	for _, test := range []struct {
+		asOf                time.Time
+		ongoing, start, end float64
+	}{
+		{time.Unix(1688410800, 0), 0, 2 * 60 * 60, 6 * 60 * 60},
+		{time.Unix(1688421600, 0), 1, 0, 3 * 60 * 60},
+	} {
+		families := gather(t, c.AsOf(test.asOf))
+		if got := gaugeValues(families["greatriverenergy_ongoing_shed_event"])[irrigation]; got != test.ongoing {
+			t.Errorf("as of %v, ongoing_shed_event{%s} = %v, want %v", test.asOf, irrigation, got, test.ongoing)
+		}
+		if got := gaugeValues(families["greatriverenergy_time_until_shed_start"])[irrigation]; got != test.start {
+			t.Errorf("as of %v, time_until_shed_start{%s} = %v, want %v", test.asOf, irrigation, got, test.start)
+		}
+		if got := gaugeValues(families["greatriverenergy_time_until_shed_end"])[irrigation]; got != test.end {
+			t.Errorf("as of %v, time_until_shed_end{%s} = %v, want %v", test.asOf, irrigation, got, test.end)
+		}
+
+		// The schedule is still reported as it is now
+		if got := gaugeValues(families["greatriverenergy_conservation_gauge"]); got[""] != 1 {
+			t.Errorf("conservation_gauge = %v, want 1", got)
+		}
+	}
+}
+
+func TestRealtime_AsOf_Health(t *testing.T) {
+	server, opt := newTestServer(t)
+	c := NewRealtime(nil, opt)
+	before := gather(t, c)
+
+	// Residential history can no longer be retrieved, which only the as-of retrieval will find
+	server.SetHistoryOptions([]lmguidetest.HistoryOption{{Code: "CI", Label: "Commercial and Industrial"}})
+	after := gather(t, c.AsOf(time.Unix(1688421600, 0)))
+
+	for _, name := range []string{
+		"greatriverenergy_scrape_success",
+		"greatriverenergy_scrape_duration_seconds",
+		"greatriverenergy_parse_errors_total",
+		"greatriverenergy_parse_warnings",
+		"greatriverenergy_last_success_timestamp_seconds",
+	} {
+		if want, got := gaugeValues(before[name]), gaugeValues(after[name]); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v after an as-of scrape, want %v", name, got, want)
+		}
+	}
+}
+
+func TestRealtime_Collect_DuplicatePrograms(t *testing.T) {
+	server, opt := newTestServer(t)
+	row := []byte("<tr class=\"BodyText_noSpaces\">\n\t\t<td>CI</td><td>C&amp;I Interruptible Metered</td>")
+	duplicates := []byte("<tr class=\"BodyText_noSpaces\">\n\t\t<td>CI</td><td>Interruptible Irrigation</td><td>Scheduled</td><td>01:00 PM - 02:00 PM</td>\n\t</tr>" +
+		"<tr class=\"BodyText_noSpaces\">\n\t\t<td>CI</td><td>Interruptible Irrigation</td><td>Likely</td><td>05:00 PM - 06:00 PM</td>\n\t</tr>" +
+		"<tr class=\"BodyText_noSpaces\">\n\t\t<td>Residential</td><td>C&amp;I with GenSet</td><td>Possible</td><td>Undetermined</td>\n\t</tr>")
+	server.SetPage("Default.aspx", bytes.Replace(lmguidetest.Fixture("Default.aspx"), row, append(duplicates, row...), 1))
+
+	// Collecting at all means there were no duplicate samples
+	families := gather(t, NewRealtime(nil, opt))
+
+	likelihood := gaugeValues(families["greatriverenergy_shed_likelihood"])
+	for labels, want := range map[string]float64{
+		`program="Interruptible Irrigation",when="today"`: 4,
+		`program="C&I with GenSet",when="today"`:          2,
+	} {
+		if got := likelihood[labels]; got != want {
+			t.Errorf("shed_likelihood{%s} = %v, want %v", labels, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+)
+
+// MetricSchema identifies a version of the metrics Realtime reports about the schedule.
+type MetricSchema int
+
+const (
+	// MetricSchemaV1 reports greatriverenergy_shed_likelihood{program,when}, with one sample per program and day.
+	MetricSchemaV1 MetricSchema = 1
+	// MetricSchemaV2 reports greatriverenergy_schedule_likelihood, and the expected start and end of each window as
+	// greatriverenergy_schedule_expected_start_timestamp_seconds and
+	// greatriverenergy_schedule_expected_end_timestamp_seconds, labeled by class, program, forecast date and window.
+	MetricSchemaV2 MetricSchema = 2
+)
+
+// WithMetricSchemas chooses which versions of the schedule metrics Realtime reports. The default is MetricSchemaV1
+// alone; reporting both eases migration from one to the other.
+func WithMetricSchemas(schemas ...MetricSchema) Option {
+	return func(o *options) {
+		o.schemas = append(o.schemas, schemas...)
+	}
+}
+
+// schema returns whether a version of the schedule metrics should be reported
+func (o options) schema(schema MetricSchema) bool {
+	if len(o.schemas) == 0 {
+		return schema == MetricSchemaV1
+	}
+	for _, s := range o.schemas {
+		if s == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// collectScheduleV2 reports a day of the schedule using MetricSchemaV2. A program which is listed more than once for
+// a day gets a window label for each listing, numbered from 1 in the order the site lists them.
+func (c Realtime) collectScheduleV2(metrics chan<- prometheus.Metric, programs []greatriverenergy.ProgramSchedule) {
+	windows := make(map[[3]string]int)
+	for _, program := range programs {
+		forecastDate := program.Date.Format("2006-01-02")
+		key := [3]string{program.Class, program.ProgramType, forecastDate}
+		windows[key]++
+		labels := []string{program.Class, program.ProgramType, forecastDate, strconv.Itoa(windows[key])}
+
+		// Omit anything which could not be parsed
+		if program.Probability != 0 {
+			metrics <- prometheus.MustNewConstMetric(c.scheduleLikelihood, prometheus.GaugeValue, float64(program.Probability), labels...)
+		}
+		if !program.ExpectedStartTime.IsZero() {
+			metrics <- prometheus.MustNewConstMetric(c.expectedStart, prometheus.GaugeValue, float64(program.ExpectedStartTime.Unix()), labels...)
+		}
+		if !program.ExpectedEndTime.IsZero() {
+			metrics <- prometheus.MustNewConstMetric(c.expectedEnd, prometheus.GaugeValue, float64(program.ExpectedEndTime.Unix()), labels...)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package exporter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+func TestWithMetricSchemas(t *testing.T) {
+	server, opt := newTestServer(t)
+	// List irrigation a second time for the next day
+	row := []byte("<tr class=\"BodyText_noSpaces\">\n\t\t<td>Residential</td><td>Cycled Air Conditioning</td><td>Unlikely</td>")
+	duplicate := []byte("<tr class=\"BodyText_noSpaces\">\n\t\t<td>CI</td><td>Interruptible Irrigation</td><td>Likely</td><td>09:00 PM - 01:00 AM</td>\n\t</tr>")
+	page := lmguidetest.Fixture("Default.aspx")
+	next := bytes.LastIndex(page, row)
+	server.SetPage("Default.aspx", append(append(append([]byte(nil), page[:next]...), duplicate...), page[next:]...))
+
+	families := gather(t, NewRealtime(nil, opt, WithMetricSchemas(MetricSchemaV2)))
+	if _, ok := families["greatriverenergy_shed_likelihood"]; ok {
+		t.Error("shed_likelihood should be omitted without MetricSchemaV1")
+	}
+
+	const first = `class="CI",forecast_date="2023-07-09",program="Interruptible Irrigation",window="1"`
+	const second = `class="CI",forecast_date="2023-07-09",program="Interruptible Irrigation",window="2"`
+
+	likelihood := gaugeValues(families["greatriverenergy_schedule_likelihood"])
+	for labels, want := range map[string]float64{
+		first:  2,
+		second: 3,
+		`class="Residential",forecast_date="2023-07-08",program="Cycled Air Conditioning",window="1"`: 1,
+	} {
+		if got, ok := likelihood[labels]; !ok || got != want {
+			t.Errorf("schedule_likelihood{%s} = %v, want %v", labels, got, want)
+		}
+	}
+	if len(likelihood) != 11 {
+		t.Errorf("expected 11 schedule_likelihood samples, got %v", len(likelihood))
+	}
+
+	// Possible and Likely rows have times too
+	start := gaugeValues(families["greatriverenergy_schedule_expected_start_timestamp_seconds"])
+	end := gaugeValues(families["greatriverenergy_schedule_expected_end_timestamp_seconds"])
+	for labels, want := range map[string][2]time.Time{
+		first:  {time.Date(2023, 7, 9, 20, 0, 0, 0, time.UTC), time.Date(2023, 7, 10, 0, 0, 0, 0, time.UTC)},
+		second: {time.Date(2023, 7, 10, 2, 0, 0, 0, time.UTC), time.Date(2023, 7, 10, 6, 0, 0, 0, time.UTC)},
+	} {
+		if got := start[labels]; got != float64(want[0].Unix()) {
+			t.Errorf("expected_start_timestamp_seconds{%s} = %v, want %v", labels, got, want[0].Unix())
+		}
+		if got := end[labels]; got != float64(want[1].Unix()) {
+			t.Errorf("expected_end_timestamp_seconds{%s} = %v, want %v", labels, got, want[1].Unix())
+		}
+	}
+	// Undetermined times are omitted
+	if len(start) != 3 || len(end) != 3 {
+		t.Errorf("expected 3 samples of each timestamp, got %v and %v", len(start), len(end))
+	}
+
+	// Both schemas can be reported at once
+	families = gather(t, NewRealtime(nil, opt, WithMetricSchemas(MetricSchemaV1, MetricSchemaV2)))
+	for _, name := range []string{"greatriverenergy_shed_likelihood", "greatriverenergy_schedule_likelihood"} {
+		if _, ok := families[name]; !ok {
+			t.Errorf("expected %v", name)
+		}
+	}
+}
@@ -1,10 +1,9 @@
 package greatriverenergy
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"math"
 	"net/http"
 	"net/url"
@@ -19,13 +18,31 @@ type History struct {
 	StartOn time.Time      `json:"startOn"`
 	EndOn   time.Time      `json:"endOn"`
 	Events  []HistoryEvent `json:"events"`
+
+	Warnings []Warning `json:"warnings,omitempty"`
 }
 
 type HistoryEvent struct {
+	// The history from which this event was retrieved, and its class (see HistoryType.Class)
+	HistoryType HistoryType `json:"historyType"`
+	Class       string      `json:"class"`
+
 	ProgramName string `json:"programName"`
-	Hours       float64
-	StartAt     time.Time `json:"startAt"`
-	EndAt       time.Time `json:"endAt"`
+	// The date on which the event started
+	Date time.Time `json:"date"`
+	// The elapsed duration of the event, which differs from RawHours if the site counted hours by the clock across a
+	// daylight saving time transition
+	Hours float64
+	// The hours exactly as displayed
+	RawHours string    `json:"rawHours"`
+	StartAt  time.Time `json:"startAt"`
+	// The end of the event, calculated as StartAt plus Hours
+	EndAt time.Time `json:"endAt"`
+
+	// The end time as displayed, which is zero if it could not be parsed
+	UpstreamEndAt time.Time `json:"upstreamEndAt"`
+	// Set if UpstreamEndAt disagrees with EndAt
+	EndMismatch bool `json:"endMismatch,omitempty"`
 }
 
 type HistoryType string
@@ -39,32 +56,55 @@ const (
 	HistoryTypeCriticalPeakPricing HistoryType = "CPP"
 	// "Public Appeal for Conservation"
 	//
-	// (This seems to not work.)
+	// (This seems to not work. Fixing it is blocked on recording the site's response to an appeal search. Until then,
+	// History expects the regular results table, which is also how lmguidetest serves appeals, so passing tests say
+	// nothing about the real site.)
 	HistoryTypePublicAppeal HistoryType = "PA"
 )
 
-func (c Client) historyFormValues(ctx context.Context, historyType HistoryType, startOn, endOn time.Time) (url.Values, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://lmguide.grenergy.com/HistoryForm.aspx", nil)
+// Class returns a short name for the class of events in this history, e.g. "R" for HistoryTypeR.
+func (ht HistoryType) Class() string {
+	switch ht {
+	case HistoryTypeR:
+		return "R"
+	default:
+		return string(ht)
+	}
+}
 
-	resp, err := c.client.Do(req)
+// endMismatchTolerance is how far the displayed end time may be from the start plus hours, which are often rounded
+const endMismatchTolerance = time.Minute
+
+const historyPage = "HistoryForm.aspx"
+
+const historyFormSelector = "form#form1"
+
+// historyForm returns the history form, loading it unless the session already has it
+func (c Client) historyForm(ctx context.Context, s *session) (*goquery.Selection, error) {
+	if s.warm() {
+		return s.form, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.pageURL(historyPage), nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("expected status code 200, got %v", resp.StatusCode)
-	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := c.inSession(s).do(req, historyPage)
 	if err != nil {
 		return nil, err
 	}
 
-	form := doc.Find("form#form1")
-	if form.AttrOr("action", "") != "./HistoryForm.aspx" {
-		return url.Values{}, fmt.Errorf("unable to find form")
+	form := doc.Find(historyFormSelector)
+	if action := form.AttrOr("action", ""); action != "./HistoryForm.aspx" {
+		return nil, scrapeError(historyPage, "history_form", historyFormSelector, doc.Find("body"), action,
+			fmt.Errorf("unable to find form"))
 	}
+	return form, nil
+}
 
+// historyFormValues returns the values with which to submit the history form
+func historyFormValues(form *goquery.Selection, historyType HistoryType, startOn, endOn time.Time) url.Values {
 	values := make(url.Values)
 	form.Find("input, select").Each(func(_ int, selection *goquery.Selection) {
 		name := selection.AttrOr("name", "")
@@ -73,7 +113,7 @@ func (c Client) historyFormValues(ctx context.Context, historyType HistoryType,
 		if strings.Contains(name, "Reset_Button") {
 			// don't click
 			return
-		} else if strings.Contains(name, "Guide") {
+		} else if selection.Is(historyGuideSelector) {
 			value = string(historyType)
 		} else if strings.Contains(name, "StartDate") {
 			value = startOn.Format("01/02/2006")
@@ -84,7 +124,7 @@ func (c Client) historyFormValues(ctx context.Context, historyType HistoryType,
 		values.Add(name, value)
 	})
 
-	return values, nil
+	return values
 }
 
 func toMidnight(t time.Time) time.Time {
@@ -97,94 +137,191 @@ func (c Client) History(ctx context.Context, historyType HistoryType, startOn, e
 	startOn = toMidnight(startOn)
 	endOn = toMidnight(endOn)
 
-	// Get the form values we need to submit
-	params, err := c.historyFormValues(ctx, historyType, startOn, endOn)
+	var events []HistoryEvent
+	var warnings []Warning
+	var err error
+	if c.historyCache != nil {
+		events, warnings, err = c.cachedHistory(ctx, historyType, startOn, endOn)
+	} else {
+		events, warnings, err = c.fetchHistory(ctx, historyType, startOn, endOn)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error loading history form: %v", err)
+		return nil, err
 	}
 
-	// Submit them
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://lmguide.grenergy.com/HistoryForm.aspx", strings.NewReader(params.Encode()))
+	// It's possible to ask for dates which might be in the future, and it's possible the
+	// API would return information for the future (i.e. today which hasn't ended yet)
+	// Make sure the endOn we return indicates which days are actually complete
+	if thisMorningAtMidnight := toMidnight(c.now()); thisMorningAtMidnight.Before(endOn) {
+		endOn = thisMorningAtMidnight
+	}
+
+	return &History{
+		StartOn: startOn,
+		EndOn:   endOn,
+		Events:  events,
+
+		Warnings: warnings,
+	}, nil
+}
+
+// submitHistoryForm submits the history form as part of a session, returning the results page
+func (c Client) submitHistoryForm(ctx context.Context, s *session, historyType HistoryType, startOn, endOn time.Time) (*response, error) {
+	warm := s.warm()
+	form, err := c.historyForm(ctx, s)
 	if err != nil {
+		return nil, fmt.Errorf("error loading history form: %w", err)
+	}
+
+	// The form is used up either way
+	s.form = nil
+
+	if err := checkHistoryType(form, historyType); err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.client.Do(req)
+	params := historyFormValues(form, historyType, startOn, endOn)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.pageURL(historyPage), strings.NewReader(params.Encode()))
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("expected status code 200, got %v", resp.StatusCode)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if warm {
+		// fetchHistory starts over with a new session if this one has been forgotten
+		return c.inSession(s).doStale(req, historyPage)
 	}
+	return c.inSession(s).do(req, historyPage)
+}
 
-	body, err := io.ReadAll(resp.Body)
+// fetchHistory retrieves the events which started between two midnights from the site, using a session from the pool
+// if there is one
+func (c Client) fetchHistory(ctx context.Context, historyType HistoryType, startOn, endOn time.Time) ([]HistoryEvent, []Warning, error) {
+	s := c.sessions.get()
+	warm := s.warm()
+	doc, err := c.submitHistoryForm(ctx, s, historyType, startOn, endOn)
+	if err != nil && warm && ctx.Err() == nil && !errors.Is(err, ErrUnknownHistoryType) {
+		// The site may have forgotten the session since it was last used, so start over with a new one
+		s = newSession()
+		doc, err = c.submitHistoryForm(ctx, s, historyType, startOn, endOn)
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
-	if err != nil {
-		return nil, err
+	// The response includes the form, ready to be submitted again
+	if form := doc.Find(historyFormSelector); form.AttrOr("action", "") == "./HistoryForm.aspx" {
+		s.form = form
+		c.sessions.put(s)
 	}
 
-	table := doc.Find("table#ContentPlaceHolder2_HistoryResults_Table")
+	const tableSelector = "table#ContentPlaceHolder2_HistoryResults_Table"
+	table := doc.Find(tableSelector)
 	if len(table.Nodes) != 1 {
-		return nil, fmt.Errorf("unable to find history table")
+		return nil, nil, scrapeError(historyPage, "history_table", tableSelector, doc.Find("form#form1"), "",
+			fmt.Errorf("unable to find history table"))
+	}
+
+	// Rows which can't be parsed are skipped when parsing tolerantly
+	p := c.newParser()
+	columns, err := p.mapColumns(historyPage, tableSelector, table, historyLayout)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	const rowSelector = tableSelector + " tr.BodyText_noSpaces"
 	var events []HistoryEvent
 	table.Find("tr.BodyText_noSpaces").Each(func(_ int, selection *goquery.Selection) {
 		if err != nil {
 			return
 		}
 
-		cells := selection.Find("td").Map(func(_ int, td *goquery.Selection) string {
-			return td.Text()
-		})
-		if len(cells) != 5 {
-			err = fmt.Errorf("expected 5 cells in each history row, got %v", len(cells))
+		cells, rowErr := columns.cells(historyPage, rowSelector, selection)
+		if rowErr != nil {
+			err = p.fail(rowErr)
 			return
 		}
+		date, program, start, end, hoursText := cells[0], cells[1], cells[2], cells[3], cells[4]
 
-		_, dateErr := time.ParseInLocation("01/02/2006", cells[0], tz)
+		day, dateErr := time.ParseInLocation("01/02/2006", date, tz)
 		if dateErr != nil {
-			err = fmt.Errorf("error parsing row ymd: %v", dateErr)
+			err = p.fail(scrapeError(historyPage, "date", rowSelector, selection, date, dateErr))
 			return
 		}
 
-		startAt, dateErr := time.ParseInLocation("01/02/2006 15:04", cells[0]+" "+cells[2], tz)
+		startWall, dateErr := parseWallClock("01/02/2006 15:04", date+" "+start)
 		if dateErr != nil {
-			err = fmt.Errorf("error parsing start time: %v", dateErr)
+			err = p.fail(scrapeError(historyPage, "start_time", rowSelector, selection, start, dateErr))
 			return
 		}
+		starts := localTimes(startWall)
 
-		hours, hoursErr := strconv.ParseFloat(cells[4], 64)
+		endWall, endErr := parseWallClock("01/02/2006 15:04", date+" "+end)
+
+		hours, hoursErr := strconv.ParseFloat(hoursText, 64)
 		if hoursErr != nil {
-			err = fmt.Errorf("error parsing hours: %v", hoursErr)
+			err = p.fail(scrapeError(historyPage, "hours", rowSelector, selection, hoursText, hoursErr))
+			return
 		}
-		seconds := math.Round(hours * 3600)
-		endAt := startAt.Add(time.Duration(seconds) * time.Second)
+		duration := time.Duration(math.Round(hours*3600)) * time.Second
 
-		events = append(events, HistoryEvent{
-			ProgramName: cells[1],
+		event := HistoryEvent{
+			HistoryType: historyType,
+			Class:       historyType.Class(),
+			ProgramName: program,
+			Date:        day,
 			Hours:       hours,
-			StartAt:     startAt,
-			EndAt:       endAt,
-		})
-	})
+			RawHours:    hoursText,
+			StartAt:     starts[0],
+			EndAt:       starts[0].Add(duration),
+		}
 
-	// It's possible to ask for dates which might be in the future, and it's possible the
-	// API would return information for the future (i.e. today which hasn't ended yet)
-	// Make sure the endOn we return indicates which days are actually complete
-	if thisMorningAtMidnight := toMidnight(time.Now()); thisMorningAtMidnight.Before(endOn) {
-		endOn = thisMorningAtMidnight
+		// The end time is redundant, so failing to parse it isn't fatal
+		if endErr != nil {
+			p.warn(scrapeError(historyPage, "end_time", rowSelector, selection, end, endErr))
+		} else if startAt, endAt, upstreamEndAt, ok := matchHistoryTimes(starts, endWall, duration); ok {
+			event.StartAt, event.EndAt, event.UpstreamEndAt = startAt, endAt, upstreamEndAt
+			if elapsed := endAt.Sub(startAt); elapsed != duration {
+				event.Hours = elapsed.Hours()
+			}
+		} else {
+			event.UpstreamEndAt = localTimesFrom(endWall, event.StartAt)[0]
+			diff := event.UpstreamEndAt.Sub(event.EndAt)
+			event.EndMismatch = true
+			p.warn(scrapeError(historyPage, "end_time", rowSelector, selection, end,
+				fmt.Errorf("end time differs from start time plus %v hours by %v", hoursText, diff)))
+		}
+
+		events = append(events, event)
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return &History{
-		StartOn: startOn,
-		EndOn:   endOn,
-		Events:  events,
-	}, nil
+	return events, p.warnings, nil
+}
+
+// matchHistoryTimes finds the start and end of an event from the wall clock readings and duration displayed, which
+// are ambiguous around daylight saving time transitions. Hours are elapsed time, so an event which starts at 00:00 on
+// the day the clocks go back and ends at 03:00 lasts 4 hours. The site might instead count 3 hours by the clock, in
+// which case the event is still taken to end at 03:00, and its Hours are corrected to 4.
+//
+// It returns the start and end of the event and the displayed end time, or false if the displayed end time disagrees
+// with the duration however the start is resolved.
+func matchHistoryTimes(starts []time.Time, endWall time.Time, duration time.Duration) (startAt, endAt, upstreamEndAt time.Time, ok bool) {
+	for _, byClock := range []bool{false, true} {
+		for _, start := range starts {
+			end := start.Add(duration)
+			if byClock {
+				end = localTimesFrom(wallClock(start).Add(duration), start)[0]
+			}
+
+			for _, upstreamEnd := range localTimesFrom(endWall, start) {
+				if diff := upstreamEnd.Sub(end); diff <= endMismatchTolerance && diff >= -endMismatchTolerance {
+					return start, end, upstreamEnd, true
+				}
+			}
+		}
+	}
+	return time.Time{}, time.Time{}, time.Time{}, false
 }
@@ -0,0 +1,217 @@
+package greatriverenergy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryCache stores the events of days which are over. Such days never change, so History only needs to retrieve
+// days which are missing from the cache or not yet complete.
+//
+// Days are identified by their midnight in Central time. Implementations must be safe for concurrent use.
+type HistoryCache interface {
+	// GetDay returns the events which started on a day, and whether the day was found in the cache. A day can be
+	// cached with no events.
+	GetDay(historyType HistoryType, day time.Time) ([]HistoryEvent, bool)
+	// PutDay stores the events which started on a day.
+	PutDay(historyType HistoryType, day time.Time, events []HistoryEvent) error
+}
+
+// WithHistoryCache makes History consult a cache for days which are over.
+func WithHistoryCache(cache HistoryCache) Option {
+	return func(c *Client) {
+		c.historyCache = cache
+	}
+}
+
+func historyCacheKey(historyType HistoryType, day time.Time) string {
+	return string(historyType) + "/" + day.In(tz).Format("2006-01-02")
+}
+
+type memoryHistoryCache struct {
+	mu   sync.RWMutex
+	days map[string][]HistoryEvent
+}
+
+// NewMemoryHistoryCache returns a HistoryCache which holds days in memory.
+func NewMemoryHistoryCache() HistoryCache {
+	return &memoryHistoryCache{days: make(map[string][]HistoryEvent)}
+}
+
+func (m *memoryHistoryCache) GetDay(historyType HistoryType, day time.Time) ([]HistoryEvent, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	events, ok := m.days[historyCacheKey(historyType, day)]
+	// Callers may modify what they get
+	return append([]HistoryEvent(nil), events...), ok
+}
+
+func (m *memoryHistoryCache) PutDay(historyType HistoryType, day time.Time, events []HistoryEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.days[historyCacheKey(historyType, day)] = append([]HistoryEvent(nil), events...)
+	return nil
+}
+
+type diskHistoryCache struct {
+	dir string
+}
+
+// NewDiskHistoryCache returns a HistoryCache which stores each day as a JSON file in a directory, so that it persists
+// across restarts. The directory is created if necessary.
+func NewDiskHistoryCache(dir string) (HistoryCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &diskHistoryCache{dir: dir}, nil
+}
+
+// path returns the file which holds a day. Types of history come from the history form, so a code which isn't a plain
+// file name is refused rather than allowed to reach outside the directory.
+func (d *diskHistoryCache) path(historyType HistoryType, day time.Time) (string, error) {
+	if code := string(historyType); !filepath.IsLocal(code) || strings.ContainsAny(code, `/\`) {
+		return "", fmt.Errorf("history type %q is not a valid file name", code)
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(historyCacheKey(historyType, day))+".json"), nil
+}
+
+func (d *diskHistoryCache) GetDay(historyType HistoryType, day time.Time) ([]HistoryEvent, bool) {
+	path, err := d.path(historyType, day)
+	if err != nil {
+		return nil, false
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var events []HistoryEvent
+	if err := json.Unmarshal(b, &events); err != nil {
+		// Treat a damaged file as missing; it'll be replaced
+		return nil, false
+	}
+
+	// Restore the time zone, which JSON represents only as an offset
+	for i := range events {
+		for _, t := range []*time.Time{&events[i].Date, &events[i].StartAt, &events[i].EndAt, &events[i].UpstreamEndAt} {
+			if !t.IsZero() {
+				*t = t.In(tz)
+			}
+		}
+	}
+	return events, true
+}
+
+func (d *diskHistoryCache) PutDay(historyType HistoryType, day time.Time, events []HistoryEvent) error {
+	if events == nil {
+		events = []HistoryEvent{}
+	}
+	b, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	path, err := d.path(historyType, day)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write atomically, so a concurrent GetDay never sees a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// cachedHistory returns the events which started between two midnights, retrieving only what isn't already cached
+func (c Client) cachedHistory(ctx context.Context, historyType HistoryType, startOn, endOn time.Time) ([]HistoryEvent, []Warning, error) {
+	today := toMidnight(c.now())
+
+	// Find the span of days which must be retrieved: everything from the first day that isn't cached through the
+	// last. Anything today or later is incomplete, and always needs retrieving.
+	cached := make(map[string][]HistoryEvent)
+	var fetchStart, fetchEnd time.Time
+	for day := startOn; !day.After(endOn); day = day.AddDate(0, 0, 1) {
+		if day.Before(today) {
+			if events, ok := c.historyCache.GetDay(historyType, day); ok {
+				cached[day.Format("2006-01-02")] = events
+				continue
+			}
+		}
+
+		if fetchStart.IsZero() {
+			fetchStart = day
+		}
+		fetchEnd = day
+	}
+
+	fetched := make(map[string][]HistoryEvent)
+	var warnings []Warning
+	if !fetchStart.IsZero() {
+		events, fetchWarnings, err := c.fetchHistory(ctx, historyType, fetchStart, fetchEnd)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = fetchWarnings
+		for _, event := range events {
+			key := event.Date.Format("2006-01-02")
+			fetched[key] = append(fetched[key], event)
+		}
+
+		// Don't cache anything if rows were skipped, since the missing events would never be retrieved again. A
+		// disagreeing end time is part of the record, and doesn't count.
+		cacheable := true
+		for _, w := range warnings {
+			if w.Field != "end_time" {
+				cacheable = false
+			}
+		}
+
+		// Cache every complete day in the span, including those without any events
+		for day := fetchStart; cacheable && !day.After(fetchEnd) && day.Before(today); day = day.AddDate(0, 0, 1) {
+			if err := c.historyCache.PutDay(historyType, day, fetched[day.Format("2006-01-02")]); err != nil {
+				warnings = append(warnings, Warning{
+					Page:    historyPage,
+					Field:   "cache",
+					Text:    day.Format("2006-01-02"),
+					Message: fmt.Sprintf("unable to cache day: %v", err),
+				})
+			}
+		}
+	}
+
+	// Assemble the events in order of day, preferring anything just retrieved
+	var events []HistoryEvent
+	for day := startOn; !day.After(endOn); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		if dayEvents, ok := fetched[key]; ok {
+			events = append(events, dayEvents...)
+		} else {
+			events = append(events, cached[key]...)
+		}
+	}
+	return events, warnings, nil
+}
@@ -0,0 +1,125 @@
+package greatriverenergy
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingTransport counts the requests made through it
+type countingTransport struct {
+	mu    sync.Mutex
+	posts int
+	gets  int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	if req.Method == http.MethodPost {
+		c.posts++
+	} else {
+		c.gets++
+	}
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClient_History_Cache(t *testing.T) {
+	memory := NewMemoryHistoryCache()
+	disk, err := NewDiskHistoryCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cache := range map[string]HistoryCache{"memory": memory, "disk": disk} {
+		t.Run(name, func(t *testing.T) {
+			_, server := newTestClient(t)
+			transport := &countingTransport{}
+			c := NewClient(transport, WithBaseURL(server.URL), WithHistoryCache(cache))
+
+			want, err := c.History(context.Background(), HistoryTypeCI, ymd(2022, 7, 10), ymd(2022, 7, 20))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transport.posts != 1 || len(want.Events) != 6 {
+				t.Fatalf("expected 1 POST and 6 events, got %v and %+v", transport.posts, want.Events)
+			}
+
+			// Every day is cached
+			got, err := c.History(context.Background(), HistoryTypeCI, ymd(2022, 7, 10), ymd(2022, 7, 20))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transport.posts != 1 || transport.gets != 1 {
+				t.Errorf("expected no further requests, got %v GETs and %v POSTs", transport.gets, transport.posts)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("cached History() = %+v\nwant %+v", got, want)
+			}
+
+			// Only the missing days are retrieved
+			got, err = c.History(context.Background(), HistoryTypeCI, ymd(2022, 7, 15), ymd(2022, 7, 25))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transport.posts != 2 {
+				t.Errorf("expected a second POST, got %v", transport.posts)
+			}
+			if !reflect.DeepEqual(got.Events, want.Events) {
+				t.Errorf("History() = %+v\nwant %+v", got.Events, want.Events)
+			}
+			if _, ok := cache.GetDay(HistoryTypeCI, ymd(2022, 7, 25)); !ok {
+				t.Error("expected 2022-07-25 to be cached")
+			}
+		})
+	}
+}
+
+func TestDiskHistoryCache_UnsafeHistoryType(t *testing.T) {
+	parent := t.TempDir()
+	cache, err := NewDiskHistoryCache(filepath.Join(parent, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, historyType := range []HistoryType{"..", "../x", `..\x`, "/x", "a/b", ""} {
+		if err := cache.PutDay(historyType, ymd(2022, 7, 10), nil); err == nil {
+			t.Errorf("PutDay(%q) succeeded", historyType)
+		}
+		if _, ok := cache.GetDay(historyType, ymd(2022, 7, 10)); ok {
+			t.Errorf("GetDay(%q) found a day", historyType)
+		}
+	}
+
+	// Nothing was written outside the cache directory
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "cache" {
+		t.Errorf("unexpected entries %v", entries)
+	}
+}
+
+func TestClient_History_CacheSkipsIncompleteDays(t *testing.T) {
+	_, server := newTestClient(t)
+	cache := NewMemoryHistoryCache()
+	c := NewClient(nil, WithBaseURL(server.URL), WithHistoryCache(cache))
+
+	today := toMidnight(time.Now())
+	if _, err := c.History(context.Background(), HistoryTypeCI, today.AddDate(0, 0, -2), today.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.GetDay(HistoryTypeCI, today.AddDate(0, 0, -1)); !ok {
+		t.Error("expected yesterday to be cached")
+	}
+	if _, ok := cache.GetDay(HistoryTypeCI, today); ok {
+		t.Error("expected today not to be cached")
+	}
+}
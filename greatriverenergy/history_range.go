@@ -0,0 +1,193 @@
+package greatriverenergy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HistoryRangeOptions controls how HistoryRange splits up and retrieves a long range of history.
+type HistoryRangeOptions struct {
+	// The number of days to request in each form submission. Defaults to 31.
+	WindowDays int
+	// The maximum number of windows to retrieve at once. Defaults to 2.
+	Concurrency int
+	// The number of times to retry a window which fails. Defaults to 2; a negative value disables retries, which
+	// suits a Client created WithRetries, since it already retries each request.
+	Retries int
+	// The delay before the first retry of a window, which doubles with each subsequent retry. Defaults to 1s.
+	RetryDelay time.Duration
+	// If set, Progress is called in chronological order as each window is retrieved, or fails for the last time.
+	Progress func(HistoryProgress)
+}
+
+// HistoryProgress describes the progress of a HistoryRange call.
+type HistoryProgress struct {
+	// The window which was just retrieved
+	StartOn, EndOn time.Time
+	// The number of attempts made to retrieve this window
+	Attempts int
+	// The error retrieving this window, if it failed
+	Err error
+
+	// The number of windows finished so far, and the total number of windows
+	Done, Total int
+}
+
+func (o HistoryRangeOptions) withDefaults() HistoryRangeOptions {
+	if o.WindowDays < 1 {
+		o.WindowDays = 31
+	}
+	if o.Concurrency < 1 {
+		o.Concurrency = 2
+	}
+	if o.Retries < 0 {
+		o.Retries = 0
+	} else if o.Retries == 0 {
+		o.Retries = 2
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = time.Second
+	}
+	return o
+}
+
+// historyWindows splits the days from startOn through endOn into windows of at most windowDays days each
+func historyWindows(startOn, endOn time.Time, windowDays int) [][2]time.Time {
+	startOn = toMidnight(startOn)
+	endOn = toMidnight(endOn)
+
+	var windows [][2]time.Time
+	for windowStart := startOn; !windowStart.After(endOn); windowStart = windowStart.AddDate(0, 0, windowDays) {
+		windowEnd := windowStart.AddDate(0, 0, windowDays-1)
+		if windowEnd.After(endOn) {
+			windowEnd = endOn
+		}
+		windows = append(windows, [2]time.Time{windowStart, windowEnd})
+	}
+	return windows
+}
+
+// HistoryRange retrieves history like History, but splits the range into windows which are retrieved separately, a
+// few at a time. Each window is retried individually if it fails. The results are merged
+// into a single History, ordered by window.
+//
+// If any window cannot be retrieved, HistoryRange returns an error. See HistoryEach to process long ranges without
+// holding every event in memory.
+func (c *Client) HistoryRange(ctx context.Context, historyType HistoryType, startOn, endOn time.Time, opts HistoryRangeOptions) (*History, error) {
+	var merged *History
+	err := c.HistoryEach(ctx, historyType, startOn, endOn, opts, func(history *History) error {
+		if merged == nil {
+			merged = &History{StartOn: history.StartOn}
+		}
+		merged.EndOn = history.EndOn
+		merged.Events = append(merged.Events, history.Events...)
+		merged.Warnings = append(merged.Warnings, history.Warnings...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// HistoryEach retrieves history in windows like HistoryRange, but instead of merging the results, it calls fn with
+// each window in chronological order as soon as it is available. At most opts.Concurrency windows are held at once,
+// so memory use does not depend on the length of the range.
+//
+// HistoryEach stops and returns the error if any window cannot be retrieved, or if fn returns an error.
+func (c *Client) HistoryEach(ctx context.Context, historyType HistoryType, startOn, endOn time.Time, opts HistoryRangeOptions, fn func(*History) error) error {
+	opts = opts.withDefaults()
+	windows := historyWindows(startOn, endOn, opts.WindowDays)
+	if len(windows) == 0 {
+		return fmt.Errorf("history range ends before it starts")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		history  *History
+		attempts int
+		err      error
+	}
+	results := make([]chan result, len(windows))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	// Each window holds a slot from when it is requested until it is passed to fn
+	slots := make(chan struct{}, opts.Concurrency)
+	go func() {
+		for i := range windows {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(i int) {
+				history, attempts, err := c.historyWindow(ctx, historyType, windows[i], opts)
+				results[i] <- result{history, attempts, err}
+			}(i)
+		}
+	}()
+
+	for i, window := range windows {
+		var r result
+		select {
+		case r = <-results[i]:
+			<-slots
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(HistoryProgress{
+				StartOn:  window[0],
+				EndOn:    window[1],
+				Attempts: r.attempts,
+				Err:      r.err,
+				Done:     i + 1,
+				Total:    len(windows),
+			})
+		}
+
+		if r.err != nil {
+			return fmt.Errorf("error retrieving history from %v to %v: %w",
+				window[0].Format("2006-01-02"), window[1].Format("2006-01-02"), r.err)
+		}
+		if err := fn(r.history); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// historyWindow retrieves one window, retrying as needed
+func (c *Client) historyWindow(ctx context.Context, historyType HistoryType, window [2]time.Time, opts HistoryRangeOptions) (*History, int, error) {
+	delay := opts.RetryDelay
+	for attempt := 1; ; attempt++ {
+		history, err := c.History(ctx, historyType, window[0], window[1])
+		if err == nil || attempt > opts.Retries || !retryableWindow(err) || ctx.Err() != nil {
+			return history, attempt, err
+		}
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return nil, attempt, err
+		}
+	}
+}
+
+// retryableWindow returns whether a window which failed with err might be retrieved if tried again. Besides the
+// failures which are never worth retrying a request for, a page which can't be parsed or a type of history the site
+// doesn't offer would only fail the same way again.
+func retryableWindow(err error) bool {
+	var scrapeErr *ScrapeError
+	return retryable(err) && !errors.As(err, &scrapeErr) && !errors.Is(err, ErrUnknownHistoryType)
+}
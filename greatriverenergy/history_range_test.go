@@ -0,0 +1,160 @@
+package greatriverenergy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistoryWindows(t *testing.T) {
+	got := historyWindows(ymd(2022, 1, 1), ymd(2022, 3, 5), 31)
+	want := [][2]time.Time{
+		{ymd(2022, 1, 1), ymd(2022, 1, 31)},
+		{ymd(2022, 2, 1), ymd(2022, 3, 3)},
+		{ymd(2022, 3, 4), ymd(2022, 3, 5)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("historyWindows() = %v, want %v", got, want)
+	}
+}
+
+// flakyTransport fails the first n form posts with a 503
+type flakyTransport struct {
+	mu       sync.Mutex
+	failures int
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	fail := req.Method == http.MethodPost && f.failures > 0
+	if fail {
+		f.failures--
+	}
+	f.mu.Unlock()
+
+	if fail {
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClient_HistoryRange(t *testing.T) {
+	_, server := newTestClient(t)
+	c := NewClient(&flakyTransport{failures: 2}, WithBaseURL(server.URL))
+
+	var progress []HistoryProgress
+	history, err := c.HistoryRange(context.Background(), HistoryTypeCI, ymd(2022, 5, 1), ymd(2022, 8, 31), HistoryRangeOptions{
+		WindowDays:  30,
+		Concurrency: 3,
+		RetryDelay:  time.Millisecond,
+		Progress: func(p HistoryProgress) {
+			progress = append(progress, p)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !history.StartOn.Equal(ymd(2022, 5, 1)) || !history.EndOn.Equal(ymd(2022, 8, 31)) {
+		t.Errorf("history covers %v to %v", history.StartOn, history.EndOn)
+	}
+	if len(history.Events) != 6 {
+		t.Errorf("expected 6 events, got %+v", history.Events)
+	}
+
+	if len(progress) != 5 {
+		t.Fatalf("expected progress for 5 windows, got %+v", progress)
+	}
+	var attempts int
+	for i, p := range progress {
+		if p.Done != i+1 || p.Total != 5 || p.Err != nil {
+			t.Errorf("unexpected progress %+v", p)
+		}
+		attempts += p.Attempts
+	}
+	if attempts != 7 {
+		t.Errorf("expected 7 attempts, got %v", attempts)
+	}
+}
+
+func TestClient_HistoryRange_Failure(t *testing.T) {
+	_, server := newTestClient(t)
+	c := NewClient(&flakyTransport{failures: 100}, WithBaseURL(server.URL))
+
+	_, err := c.HistoryRange(context.Background(), HistoryTypeCI, ymd(2022, 5, 1), ymd(2022, 8, 31), HistoryRangeOptions{
+		Retries:    -1,
+		RetryDelay: time.Millisecond,
+	})
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestClient_HistoryRange_Unretryable(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	var progress []HistoryProgress
+	_, err := c.HistoryRange(context.Background(), HistoryType("XX"), ymd(2022, 5, 1), ymd(2022, 5, 31), HistoryRangeOptions{
+		RetryDelay: time.Millisecond,
+		Progress: func(p HistoryProgress) {
+			progress = append(progress, p)
+		},
+	})
+	if !errors.Is(err, ErrUnknownHistoryType) {
+		t.Errorf("expected ErrUnknownHistoryType, got %v", err)
+	}
+	if len(progress) != 1 || progress[0].Attempts != 1 {
+		t.Errorf("expected one attempt, got %+v", progress)
+	}
+}
+
+func TestClient_HistoryEach(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	var windows [][2]time.Time
+	var events int
+	err := c.HistoryEach(context.Background(), HistoryTypeCI, ymd(2022, 1, 1), ymd(2022, 12, 31), HistoryRangeOptions{
+		WindowDays:  7,
+		Concurrency: 4,
+	}, func(history *History) error {
+		windows = append(windows, [2]time.Time{history.StartOn, history.EndOn})
+		events += len(history.Events)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(windows) != 53 {
+		t.Errorf("expected 53 windows, got %v", len(windows))
+	}
+	for i := 1; i < len(windows); i++ {
+		if !windows[i][0].Equal(windows[i-1][1].AddDate(0, 0, 1)) {
+			t.Errorf("window %v starts on %v, after a window ending on %v", i, windows[i][0], windows[i-1][1])
+		}
+	}
+	if events != 6 {
+		t.Errorf("expected 6 events, got %v", events)
+	}
+
+	// Returning an error stops iteration
+	stop := errors.New("stop")
+	var calls int
+	err = c.HistoryEach(context.Background(), HistoryTypeCI, ymd(2022, 1, 1), ymd(2022, 12, 31), HistoryRangeOptions{
+		WindowDays: 7,
+	}, func(history *History) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("HistoryEach() = %v after %v calls", err, calls)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"sort"
 	"testing"
 	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
 )
 
 func ymd(y, m, d int) time.Time {
@@ -26,16 +28,26 @@ func TestClient_History(t *testing.T) {
 			ymd(2021, 7, 1), ymd(2021, 7, 4), HistoryTypeR,
 			[]HistoryEvent{
 				{
-					ProgramName: "Interruptible Water Heating",
-					Hours:       5.5,
-					StartAt:     ymdhm(2021, 7, 4, 15, 0),
-					EndAt:       ymdhm(2021, 7, 4, 20, 30),
+					HistoryType:   HistoryTypeR,
+					Class:         "R",
+					ProgramName:   "Interruptible Water Heating",
+					Date:          ymd(2021, 7, 4),
+					Hours:         5.5,
+					RawHours:      "5.5",
+					StartAt:       ymdhm(2021, 7, 4, 15, 0),
+					EndAt:         ymdhm(2021, 7, 4, 20, 30),
+					UpstreamEndAt: ymdhm(2021, 7, 4, 20, 30),
 				},
 				{
-					ProgramName: "Cycled Air Conditioning",
-					Hours:       4.0,
-					StartAt:     ymdhm(2021, 7, 4, 15, 30),
-					EndAt:       ymdhm(2021, 7, 4, 19, 30),
+					HistoryType:   HistoryTypeR,
+					Class:         "R",
+					ProgramName:   "Cycled Air Conditioning",
+					Date:          ymd(2021, 7, 4),
+					Hours:         4.0,
+					RawHours:      "4",
+					StartAt:       ymdhm(2021, 7, 4, 15, 30),
+					EndAt:         ymdhm(2021, 7, 4, 19, 30),
+					UpstreamEndAt: ymdhm(2021, 7, 4, 19, 30),
 				},
 			},
 		},
@@ -47,47 +59,77 @@ func TestClient_History(t *testing.T) {
 			ymd(2022, 7, 1), ymd(2022, 7, 18), HistoryTypeCI,
 			[]HistoryEvent{
 				{
-					ProgramName: "Interruptible Irrigation",
-					Hours:       4,
-					StartAt:     ymdhm(2022, 7, 17, 15, 0),
-					EndAt:       ymdhm(2022, 7, 17, 19, 0),
+					HistoryType:   HistoryTypeCI,
+					Class:         "CI",
+					ProgramName:   "Interruptible Irrigation",
+					Date:          ymd(2022, 7, 17),
+					Hours:         4,
+					RawHours:      "4",
+					StartAt:       ymdhm(2022, 7, 17, 15, 0),
+					EndAt:         ymdhm(2022, 7, 17, 19, 0),
+					UpstreamEndAt: ymdhm(2022, 7, 17, 19, 0),
 				},
 				{
-					ProgramName: "Interruptible Irrigation",
-					Hours:       4,
-					StartAt:     ymdhm(2022, 7, 18, 15, 0),
-					EndAt:       ymdhm(2022, 7, 18, 19, 0),
+					HistoryType:   HistoryTypeCI,
+					Class:         "CI",
+					ProgramName:   "Interruptible Irrigation",
+					Date:          ymd(2022, 7, 18),
+					Hours:         4,
+					RawHours:      "4",
+					StartAt:       ymdhm(2022, 7, 18, 15, 0),
+					EndAt:         ymdhm(2022, 7, 18, 19, 0),
+					UpstreamEndAt: ymdhm(2022, 7, 18, 19, 0),
 				},
 				{
-					ProgramName: "C&I Interruptible Metered",
-					Hours:       6,
-					StartAt:     ymdhm(2022, 7, 18, 14, 0),
-					EndAt:       ymdhm(2022, 7, 18, 20, 0),
+					HistoryType:   HistoryTypeCI,
+					Class:         "CI",
+					ProgramName:   "C&I Interruptible Metered",
+					Date:          ymd(2022, 7, 18),
+					Hours:         6,
+					RawHours:      "6",
+					StartAt:       ymdhm(2022, 7, 18, 14, 0),
+					EndAt:         ymdhm(2022, 7, 18, 20, 0),
+					UpstreamEndAt: ymdhm(2022, 7, 18, 20, 0),
 				},
 				{
-					ProgramName: "C&I with GenSet",
-					Hours:       6,
-					StartAt:     ymdhm(2022, 7, 18, 14, 0),
-					EndAt:       ymdhm(2022, 7, 18, 20, 0),
+					HistoryType:   HistoryTypeCI,
+					Class:         "CI",
+					ProgramName:   "C&I with GenSet",
+					Date:          ymd(2022, 7, 18),
+					Hours:         6,
+					RawHours:      "6",
+					StartAt:       ymdhm(2022, 7, 18, 14, 0),
+					EndAt:         ymdhm(2022, 7, 18, 20, 0),
+					UpstreamEndAt: ymdhm(2022, 7, 18, 20, 0),
 				},
 				{
-					ProgramName: "Group B C&I Interruptible Metered",
-					Hours:       6,
-					StartAt:     ymdhm(2022, 7, 18, 14, 0),
-					EndAt:       ymdhm(2022, 7, 18, 20, 0),
+					HistoryType:   HistoryTypeCI,
+					Class:         "CI",
+					ProgramName:   "Group B C&I Interruptible Metered",
+					Date:          ymd(2022, 7, 18),
+					Hours:         6,
+					RawHours:      "6",
+					StartAt:       ymdhm(2022, 7, 18, 14, 0),
+					EndAt:         ymdhm(2022, 7, 18, 20, 0),
+					UpstreamEndAt: ymdhm(2022, 7, 18, 20, 0),
 				},
 				{
-					ProgramName: "Group B C&I with GenSet",
-					Hours:       6,
-					StartAt:     ymdhm(2022, 7, 18, 14, 0),
-					EndAt:       ymdhm(2022, 7, 18, 20, 0),
+					HistoryType:   HistoryTypeCI,
+					Class:         "CI",
+					ProgramName:   "Group B C&I with GenSet",
+					Date:          ymd(2022, 7, 18),
+					Hours:         6,
+					RawHours:      "6",
+					StartAt:       ymdhm(2022, 7, 18, 14, 0),
+					EndAt:         ymdhm(2022, 7, 18, 20, 0),
+					UpstreamEndAt: ymdhm(2022, 7, 18, 20, 0),
 				},
 			},
 		},
 	} {
 		name := string(tc.historyType) + " " + tc.startOn.Format("20060102") + " " + tc.endOn.Format("20060102")
 		t.Run(name, func(t *testing.T) {
-			c := NewClient(nil)
+			c, _ := newTestClient(t)
 			history, err := c.History(context.Background(), tc.historyType, tc.startOn, tc.endOn)
 
 			if err != nil {
@@ -121,3 +163,114 @@ func TestClient_History(t *testing.T) {
 	}
 
 }
+
+func TestClient_History_CriticalPeakPricing(t *testing.T) {
+	c, server := newTestClient(t)
+	server.SetHistory("CPP", []lmguidetest.HistoryRow{
+		{Date: "08/23/2022", Program: "Critical Peak Pricing", Start: "16:00", End: "20:00", Hours: "4"},
+	})
+
+	history, err := c.History(context.Background(), HistoryTypeCriticalPeakPricing, ymd(2022, 8, 1), ymd(2022, 8, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HistoryEvent{
+		{
+			HistoryType:   HistoryTypeCriticalPeakPricing,
+			Class:         "CPP",
+			ProgramName:   "Critical Peak Pricing",
+			Date:          ymd(2022, 8, 23),
+			Hours:         4,
+			RawHours:      "4",
+			StartAt:       ymdhm(2022, 8, 23, 16, 0),
+			EndAt:         ymdhm(2022, 8, 23, 20, 0),
+			UpstreamEndAt: ymdhm(2022, 8, 23, 20, 0),
+		},
+	}
+	if !reflect.DeepEqual(history.Events, want) {
+		t.Errorf("History() returned %+v, want %+v", history.Events, want)
+	}
+}
+
+func TestClient_History_EndTime(t *testing.T) {
+	c, server := newTestClient(t)
+	server.SetHistory("CI", []lmguidetest.HistoryRow{
+		// hours are rounded to two places
+		{Date: "07/18/2022", Program: "Interruptible Irrigation", Start: "15:00", End: "19:20", Hours: "4.33"},
+		// ends the next day
+		{Date: "07/18/2022", Program: "C&I with GenSet", Start: "22:00", End: "01:00", Hours: "3"},
+		// disagrees
+		{Date: "07/18/2022", Program: "C&I Interruptible Metered", Start: "14:00", End: "21:00", Hours: "6"},
+	})
+
+	history, err := c.History(context.Background(), HistoryTypeCI, ymd(2022, 7, 18), ymd(2022, 7, 18))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Events) != 3 {
+		t.Fatalf("Events = %+v", history.Events)
+	}
+
+	for i, want := range []struct {
+		upstreamEndAt time.Time
+		mismatch      bool
+	}{
+		{ymdhm(2022, 7, 18, 19, 20), false},
+		{ymdhm(2022, 7, 19, 1, 0), false},
+		{ymdhm(2022, 7, 18, 21, 0), true},
+	} {
+		event := history.Events[i]
+		if !event.UpstreamEndAt.Equal(want.upstreamEndAt) || event.EndMismatch != want.mismatch {
+			t.Errorf("%s: UpstreamEndAt = %v, EndMismatch = %v; want %v, %v", event.ProgramName, event.UpstreamEndAt, event.EndMismatch, want.upstreamEndAt, want.mismatch)
+		}
+	}
+
+	if len(history.Warnings) != 1 || history.Warnings[0].Field != "end_time" || history.Warnings[0].Text != "21:00" {
+		t.Errorf("Warnings = %+v", history.Warnings)
+	}
+}
+
+func TestClient_History_DaylightSaving(t *testing.T) {
+	c, server := newTestClient(t)
+	server.SetHistory("RES", []lmguidetest.HistoryRow{
+		// March 12, 2023: the clocks go forward from 02:00 to 03:00, so these last 2 hours
+		{Date: "03/12/2023", Program: "Elapsed", Start: "01:00", End: "04:00", Hours: "2.00"},
+		{Date: "03/12/2023", Program: "By the clock", Start: "01:00", End: "04:00", Hours: "3.00"},
+		// November 5, 2023: the clocks go back from 02:00 to 01:00, so these last 4 hours
+		{Date: "11/05/2023", Program: "Elapsed", Start: "00:00", End: "03:00", Hours: "4.00"},
+		{Date: "11/05/2023", Program: "By the clock", Start: "00:00", End: "03:00", Hours: "3.00"},
+		// 01:30 happens twice, and the hours say which
+		{Date: "11/05/2023", Program: "First 01:30", Start: "01:30", End: "02:00", Hours: "1.50"},
+		{Date: "11/05/2023", Program: "Second 01:30", Start: "01:30", End: "02:00", Hours: "0.50"},
+	})
+
+	history, err := c.History(context.Background(), HistoryTypeR, ymd(2023, 3, 1), ymd(2023, 11, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Warnings) != 0 {
+		t.Errorf("Warnings = %+v", history.Warnings)
+	}
+
+	want := []struct {
+		start, end time.Time
+		hours      float64
+	}{
+		{utc(2023, 3, 12, 7, 0), utc(2023, 3, 12, 9, 0), 2},
+		{utc(2023, 3, 12, 7, 0), utc(2023, 3, 12, 9, 0), 2},
+		{utc(2023, 11, 5, 5, 0), utc(2023, 11, 5, 9, 0), 4},
+		{utc(2023, 11, 5, 5, 0), utc(2023, 11, 5, 9, 0), 4},
+		{utc(2023, 11, 5, 6, 30), utc(2023, 11, 5, 8, 0), 1.5},
+		{utc(2023, 11, 5, 7, 30), utc(2023, 11, 5, 8, 0), 0.5},
+	}
+	if len(history.Events) != len(want) {
+		t.Fatalf("History() returned %+v", history.Events)
+	}
+	for i, event := range history.Events {
+		if !event.StartAt.Equal(want[i].start) || !event.EndAt.Equal(want[i].end) || !event.UpstreamEndAt.Equal(want[i].end) || event.Hours != want[i].hours {
+			t.Errorf("%s on %s: %v to %v (displayed %v) after %v hours, want %v to %v after %v hours",
+				event.ProgramName, event.Date.Format("2006-01-02"), event.StartAt.UTC(), event.EndAt.UTC(), event.UpstreamEndAt.UTC(), event.Hours,
+				want[i].start, want[i].end, want[i].hours)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package greatriverenergy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// HistoryTypeOption is a type of history offered by the history form.
+type HistoryTypeOption struct {
+	Type HistoryType `json:"type"`
+	// The label shown for this type, e.g. "Residential"
+	Label string `json:"label"`
+}
+
+// ErrUnknownHistoryType is returned when requesting a type of history which the history form doesn't offer.
+var ErrUnknownHistoryType = errors.New("unknown history type")
+
+const historyGuideSelector = `select[name*="Guide"]`
+
+// parseHistoryTypes reads the types of history offered by the history form
+func parseHistoryTypes(form *goquery.Selection) ([]HistoryTypeOption, error) {
+	guide := form.Find(historyGuideSelector)
+	if guide.Length() != 1 {
+		return nil, scrapeError(historyPage, "history_types", historyGuideSelector, form, "",
+			fmt.Errorf("expected 1 select, found %v", guide.Length()))
+	}
+
+	var options []HistoryTypeOption
+	guide.Find("option").Each(func(_ int, option *goquery.Selection) {
+		code := strings.TrimSpace(option.AttrOr("value", ""))
+		if code == "" {
+			return
+		}
+		options = append(options, HistoryTypeOption{
+			Type:  HistoryType(code),
+			Label: strings.Join(strings.Fields(option.Text()), " "),
+		})
+	})
+	if len(options) == 0 {
+		return nil, scrapeError(historyPage, "history_types", historyGuideSelector+" option", guide, "",
+			fmt.Errorf("no history types offered"))
+	}
+	return options, nil
+}
+
+// checkHistoryType returns ErrUnknownHistoryType if the history form doesn't offer a type of history
+func checkHistoryType(form *goquery.Selection, historyType HistoryType) error {
+	options, err := parseHistoryTypes(form)
+	if err != nil {
+		return err
+	}
+
+	var codes []string
+	for _, option := range options {
+		if option.Type == historyType {
+			return nil
+		}
+		codes = append(codes, string(option.Type))
+	}
+	return fmt.Errorf("%w %q (the site offers %s)", ErrUnknownHistoryType, historyType, strings.Join(codes, ", "))
+}
+
+// HistoryTypes returns the types of history which the site offers, as listed by the history form.
+func (c Client) HistoryTypes(ctx context.Context) ([]HistoryTypeOption, error) {
+	s := c.sessions.get()
+	form, err := c.historyForm(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("error loading history form: %w", err)
+	}
+
+	options, err := parseHistoryTypes(form)
+	if err != nil {
+		return nil, err
+	}
+
+	// The form is still ready for submission
+	s.form = form
+	c.sessions.put(s)
+	return options, nil
+}
@@ -0,0 +1,72 @@
+package greatriverenergy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+func TestClient_HistoryTypes(t *testing.T) {
+	c, server := newTestClient(t)
+
+	got, err := c.HistoryTypes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HistoryTypeOption{
+		{HistoryTypeR, "Residential"},
+		{HistoryTypeCI, "Commercial and Industrial"},
+		{HistoryTypeCriticalPeakPricing, "Critical Peak Pricing"},
+		{HistoryTypePublicAppeal, "Public Appeal for Conservation"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HistoryTypes() = %+v, want %+v", got, want)
+	}
+
+	// Types are discovered, not hard-coded
+	server.SetHistoryOptions([]lmguidetest.HistoryOption{{Code: "RES", Label: "Residential"}, {Code: "EV", Label: "Electric Vehicles"}})
+	got, err = c.HistoryTypes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []HistoryTypeOption{{HistoryTypeR, "Residential"}, {"EV", "Electric Vehicles"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HistoryTypes() = %+v, want %+v", got, want)
+	}
+}
+
+func TestClient_History_UnknownType(t *testing.T) {
+	_, server := newTestClient(t)
+	transport := &countingTransport{}
+	c := NewClient(transport, WithBaseURL(server.URL), WithSessionPool(1, 0))
+
+	_, err := c.History(context.Background(), "XYZ", ymd(2023, 7, 1), ymd(2023, 7, 31))
+	if !errors.Is(err, ErrUnknownHistoryType) {
+		t.Errorf("History() = %v, want ErrUnknownHistoryType", err)
+	}
+	if transport.gets != 1 || transport.posts != 0 {
+		t.Errorf("History() made %v GETs and %v POSTs, want 1 and 0", transport.gets, transport.posts)
+	}
+}
+
+func TestWithSessionPool_HistoryTypes(t *testing.T) {
+	_, server := newTestClient(t)
+	transport := &countingTransport{}
+	c := NewClient(transport, WithBaseURL(server.URL), WithSessionPool(1, 0))
+	ctx := context.Background()
+
+	if _, err := c.HistoryTypes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The form loaded to find the types can be submitted
+	if _, err := c.History(ctx, HistoryTypeCI, ymd(2023, 7, 1), ymd(2023, 7, 31)); err != nil {
+		t.Fatal(err)
+	}
+	if transport.gets != 1 || transport.posts != 1 {
+		t.Errorf("made %v GETs and %v POSTs, want 1 and 1", transport.gets, transport.posts)
+	}
+}
@@ -0,0 +1,49 @@
+package lmguidetest
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestServer_HistoryFormRequiresSession(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	form := url.Values{
+		"__VIEWSTATE": {"/wEPDwULLTE00000001ZGQ="},
+		"ctl00$ContentPlaceHolder2$Guide_DropDownList": {"RES"},
+		"ctl00$ContentPlaceHolder2$Submit_Button":      {"Submit"},
+	}
+
+	// Without a session, the postback must be rejected
+	resp, err := http.PostForm(server.URL+"/HistoryForm.aspx", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("POST without session: status %v, want 500", resp.StatusCode)
+	}
+
+	// With a session but a stale ViewState, the postback must also be rejected
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	resp, err = client.Get(server.URL + "/HistoryForm.aspx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	form.Set("__VIEWSTATE", "stale")
+	resp, err = client.Post(server.URL+"/HistoryForm.aspx", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("POST with stale ViewState: status %v, want 500", resp.StatusCode)
+	}
+}
@@ -0,0 +1,64 @@
+package greatriverenergy
+
+import "fmt"
+
+// Warning describes part of a page which could not be parsed, and which was therefore skipped or kept in raw form.
+type Warning struct {
+	Page     string `json:"page"`
+	Field    string `json:"field"`
+	Selector string `json:"selector,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Text != "" {
+		return fmt.Sprintf("%s: %s: %s (text %q)", w.Page, w.Field, w.Message, w.Text)
+	}
+	return fmt.Sprintf("%s: %s: %s", w.Page, w.Field, w.Message)
+}
+
+// WithTolerantParsing makes Schedule, ShedCounts and History return whatever they could parse, along with Warnings
+// describing anything they could not, instead of failing outright. Unrecognized values are kept in their raw form.
+//
+// Without this option, these calls return a ScrapeError for anything other than unparseable expected times.
+func WithTolerantParsing() Option {
+	return func(c *Client) {
+		c.tolerant = true
+	}
+}
+
+// parser accumulates warnings while scraping a page
+type parser struct {
+	tolerant     bool
+	fixedColumns bool
+	warnings     []Warning
+}
+
+func (c Client) newParser() *parser {
+	return &parser{tolerant: c.tolerant, fixedColumns: c.fixedColumns}
+}
+
+// warn records a problem which does not prevent the page from being parsed
+func (p *parser) warn(err *ScrapeError) {
+	w := Warning{
+		Page:     err.Page,
+		Field:    err.Field,
+		Selector: err.Selector,
+		Text:     err.Text,
+	}
+	if err.Err != nil {
+		w.Message = err.Err.Error()
+	}
+	p.warnings = append(p.warnings, w)
+}
+
+// fail records a problem which would prevent the page from being parsed strictly. If parsing is tolerant, it is
+// recorded as a warning and fail returns nil, in which case the caller should skip or keep the offending value.
+func (p *parser) fail(err *ScrapeError) error {
+	if !p.tolerant {
+		return err
+	}
+	p.warn(err)
+	return nil
+}
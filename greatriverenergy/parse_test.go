@@ -0,0 +1,113 @@
+package greatriverenergy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+// unusualSchedule is Default.aspx with an unknown gauge image, an unknown probability, and an unparseable time
+func unusualSchedule() []byte {
+	page := lmguidetest.Fixture("Default.aspx")
+	page = bytes.Replace(page, []byte("images/gauge1.jpg"), []byte("images/gauge5.jpg"), 1)
+	page = bytes.Replace(page, []byte("<td>Possible</td><td>03:00 PM - 07:00 PM</td>"), []byte("<td>Imminent</td><td>03:00 PM - 07:00 PM</td>"), 1)
+	page = bytes.Replace(page, []byte("03:00 PM - 08:30 PM"), []byte("03:00 PM - Late"), 1)
+	return page
+}
+
+func TestClient_Schedule_Tolerant(t *testing.T) {
+	c, server := newTestClient(t)
+	server.SetPage("Default.aspx", unusualSchedule())
+
+	// Strict parsing fails
+	var scrapeErr *ScrapeError
+	if _, err := c.Schedule(context.Background()); !errors.As(err, &scrapeErr) {
+		t.Errorf("expected a ScrapeError, got %v", err)
+	}
+
+	// Tolerant parsing does not
+	c = NewClient(nil, WithBaseURL(server.URL), WithTolerantParsing())
+	schedule, err := c.Schedule(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schedule.ConservationGauge != 0 || schedule.ConservationGaugeSrc != "images/gauge5.jpg" {
+		t.Errorf("ConservationGauge = %v, ConservationGaugeSrc = %q", schedule.ConservationGauge, schedule.ConservationGaugeSrc)
+	}
+	if len(schedule.Today) != 5 || len(schedule.NextDay) != 5 {
+		t.Fatalf("expected 5 programs on each day, got %v and %v", len(schedule.Today), len(schedule.NextDay))
+	}
+
+	irrigation := schedule.NextDay[2]
+	if irrigation.Probability != 0 || irrigation.RawProbability != "Imminent" {
+		t.Errorf("Probability = %v, RawProbability = %q", irrigation.Probability, irrigation.RawProbability)
+	}
+	if irrigation.ExpectedStartTime != ymdhm(2023, 7, 9, 15, 0) {
+		t.Errorf("ExpectedStartTime = %v", irrigation.ExpectedStartTime)
+	}
+
+	waterHeating := schedule.NextDay[4]
+	if waterHeating.Probability != ProbabilityPossible || !waterHeating.ExpectedEndTime.IsZero() || waterHeating.RawExpectedTime != "03:00 PM - Late" {
+		t.Errorf("unexpected %+v", waterHeating)
+	}
+
+	var fields []string
+	for _, w := range schedule.Warnings {
+		fields = append(fields, w.Field+"="+w.Text)
+	}
+	want := []string{"conservation_gauge=images/gauge5.jpg", "probability=Imminent", "expected_end_time=Late"}
+	if len(fields) != len(want) {
+		t.Fatalf("Warnings = %v, want %v", fields, want)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("Warnings = %v, want %v", fields, want)
+		}
+	}
+}
+
+func TestClient_ShedCounts_Tolerant(t *testing.T) {
+	_, server := newTestClient(t)
+	server.SetPage("ShedCount.aspx", bytes.Replace(lmguidetest.Fixture("ShedCount.aspx"), []byte("<td>214</td>"), []byte("<td>n/a</td>"), 1))
+
+	c := NewClient(nil, WithBaseURL(server.URL), WithTolerantParsing())
+	counts, err := c.ShedCounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := counts.Table["Dual Fuel"]; ok {
+		t.Error("unparseable count should have been skipped")
+	}
+	if len(counts.Table) != 12 {
+		t.Errorf("expected 12 counts, got %v", len(counts.Table))
+	}
+	if len(counts.Warnings) != 1 || counts.Warnings[0].Text != "n/a" {
+		t.Errorf("Warnings = %+v", counts.Warnings)
+	}
+}
+
+func TestClient_History_Tolerant(t *testing.T) {
+	_, server := newTestClient(t)
+	server.SetHistory("RES", []lmguidetest.HistoryRow{
+		{Date: "07/04/2021", Program: "Interruptible Water Heating", Start: "15:00", End: "20:30", Hours: "5.5"},
+		{Date: "07/04/2021", Program: "Cycled Air Conditioning", Start: "15:30", End: "19:30", Hours: "about 4"},
+	})
+
+	c := NewClient(nil, WithBaseURL(server.URL), WithTolerantParsing())
+	history, err := c.History(context.Background(), HistoryTypeR, ymd(2021, 7, 1), ymd(2021, 7, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history.Events) != 1 || history.Events[0].ProgramName != "Interruptible Water Heating" {
+		t.Errorf("Events = %+v", history.Events)
+	}
+	if len(history.Warnings) != 1 || history.Warnings[0].Field != "hours" || history.Warnings[0].Text != "about 4" {
+		t.Errorf("Warnings = %+v", history.Warnings)
+	}
+}
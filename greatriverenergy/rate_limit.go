@@ -0,0 +1,106 @@
+package greatriverenergy
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate of requests to the site using a token bucket. Share one RateLimiter between every Client
+// in a process to bound the load they place on the site together.
+//
+// A RateLimiter is safe for concurrent use.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	stats  RateLimiterStats
+}
+
+// RateLimiterStats describes the requests which have waited on a RateLimiter.
+type RateLimiterStats struct {
+	// The number of requests waiting right now
+	Queued int
+	// The number of requests which have had to wait
+	Delayed uint64
+	// The total time requests have spent waiting
+	Waited time.Duration
+}
+
+// NewRateLimiter returns a RateLimiter which allows requestsPerSecond on average, and bursts of up to burst requests
+// at once.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// WithRateLimiter makes the Client wait on a RateLimiter before every request, including retries.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// Stats returns statistics describing the requests which have waited on the limiter.
+func (l *RateLimiter) Stats() RateLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// wait blocks until a request may be made, or until ctx is done
+func (l *RateLimiter) wait(ctx context.Context) error {
+	if l.rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// Take a token, going into debt if there aren't any. The debt determines how long to wait, so that requests are
+	// let through in the order in which they arrived.
+	l.tokens--
+	if l.tokens >= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.stats.Queued++
+	l.stats.Delayed++
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Queued--
+	l.stats.Waited += time.Since(now)
+	if err != nil {
+		// Give back the token, since no request will be made
+		l.tokens++
+	}
+	return err
+}
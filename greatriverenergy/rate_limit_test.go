@@ -0,0 +1,62 @@
+package greatriverenergy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	_, server := newTestClient(t)
+	limiter := NewRateLimiter(50, 2)
+
+	// Separate clients share the limiter
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := NewClient(nil, WithBaseURL(server.URL), WithRateLimiter(limiter))
+			if _, err := c.Schedule(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Two requests go immediately, and the remaining four are spaced 20ms apart
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("6 requests took %v, want about 80ms", elapsed)
+	}
+	stats := limiter.Stats()
+	if stats.Queued != 0 || stats.Delayed != 4 || stats.Waited < 150*time.Millisecond {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestRateLimiter_Cancel(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait() = %v, want context.DeadlineExceeded", err)
+	}
+	if stats := limiter.Stats(); stats.Queued != 0 || stats.Delayed != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	// The cancelled request shouldn't have used up a token
+	limiter.mu.Lock()
+	tokens := limiter.tokens
+	limiter.mu.Unlock()
+	if tokens < -0.1 {
+		t.Errorf("%v tokens after cancellation", tokens)
+	}
+}
@@ -0,0 +1,301 @@
+// Package recorder captures conversations with the load management site and plays them back later, so that a real
+// session (an outage day, a layout change) can be turned into a regression test or attached to a bug report.
+//
+// Each request/response pair is stored in a directory as two files in HTTP/1.1 wire format, numbered in the order
+// the requests were made:
+//
+//	0001-request.http
+//	0001-response.http
+//	0002-request.http
+//	…
+//
+// Recording into a directory which already holds a recording continues its numbering, so nothing is overwritten.
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Recorder is an http.RoundTripper which saves every request and response that passes through it.
+type Recorder struct {
+	dir  string
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	serial int
+}
+
+// NewRecorder returns a Recorder which sends requests through next and saves them to dir, creating it if necessary.
+// If dir already holds a recording, new pairs are numbered after it. If next is nil, http.DefaultTransport is used.
+func NewRecorder(dir string, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	requestFiles, err := recordedRequests(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{dir: dir, next: next}
+	if len(requestFiles) > 0 {
+		r.serial, _ = recordedSerial(requestFiles[len(requestFiles)-1])
+	}
+	return r, nil
+}
+
+// recordedRequests returns the request files in dir, in the order they were recorded
+func recordedRequests(dir string) ([]string, error) {
+	requestFiles, err := filepath.Glob(filepath.Join(dir, "*-request.http"))
+	if err != nil {
+		return nil, err
+	}
+
+	serials := make(map[string]int, len(requestFiles))
+	for _, requestFile := range requestFiles {
+		serial, err := recordedSerial(requestFile)
+		if err != nil {
+			return nil, err
+		}
+		serials[requestFile] = serial
+	}
+	// Numerically, since a recording may outgrow the four digits the serial is padded to
+	sort.Slice(requestFiles, func(i, j int) bool { return serials[requestFiles[i]] < serials[requestFiles[j]] })
+	return requestFiles, nil
+}
+
+// recordedSerial returns the serial number of a recorded file
+func recordedSerial(file string) (int, error) {
+	name := filepath.Base(file)
+	serial, err := strconv.Atoi(name[:strings.IndexByte(name, '-')])
+	if err != nil {
+		return 0, fmt.Errorf("recorder: unexpected file %q", file)
+	}
+	return serial, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBytes, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: unable to dump request: %v", err)
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		// Nothing useful to replay
+		return nil, err
+	}
+
+	respBytes, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("recorder: unable to dump response: %v", err)
+	}
+
+	r.mu.Lock()
+	r.serial++
+	prefix := filepath.Join(r.dir, fmt.Sprintf("%04d", r.serial))
+	r.mu.Unlock()
+
+	if err := os.WriteFile(prefix+"-request.http", reqBytes, 0o644); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("recorder: %v", err)
+	}
+	if err := os.WriteFile(prefix+"-response.http", respBytes, 0o644); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("recorder: %v", err)
+	}
+
+	return resp, nil
+}
+
+// Replayer is an http.RoundTripper which answers requests from a directory written by a Recorder, without touching
+// the network.
+//
+// Recorded pairs are served in the order recorded. A request is answered by the first unused pair with the same method,
+// path and query, and for form posts, the same form values other than ASP.NET's hidden state fields (__VIEWSTATE and
+// friends), which change on every visit. This keeps replays working when independent pages are fetched in a different
+// order than when they were recorded.
+//
+// If no unused pair matches exactly, the first unused pair with the same method and path is served instead. Form posts
+// carry dates relative to the time of the request, and a recording made on one day must still replay on the next.
+//
+// Once every matching pair has been used, the last of them keeps being served, so that a poller can run for longer
+// than the recording did.
+type Replayer struct {
+	mu    sync.Mutex
+	pairs []*pair
+}
+
+type pair struct {
+	used     bool
+	method   string
+	url      *url.URL
+	form     url.Values
+	response []byte
+}
+
+// NewReplayer loads the pairs recorded in dir.
+func NewReplayer(dir string) (*Replayer, error) {
+	requestFiles, err := recordedRequests(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(requestFiles) == 0 {
+		return nil, fmt.Errorf("recorder: no recorded requests in %q", dir)
+	}
+
+	r := &Replayer{}
+	for _, requestFile := range requestFiles {
+		reqBytes, err := os.ReadFile(requestFile)
+		if err != nil {
+			return nil, err
+		}
+		respBytes, err := os.ReadFile(strings.TrimSuffix(requestFile, "-request.http") + "-response.http")
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(reqBytes)))
+		if err != nil {
+			return nil, fmt.Errorf("recorder: unable to parse %q: %v", requestFile, err)
+		}
+		form, err := formValues(req)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: unable to parse %q: %v", requestFile, err)
+		}
+
+		r.pairs = append(r.pairs, &pair{
+			method:   req.Method,
+			url:      req.URL,
+			form:     form,
+			response: respBytes,
+		})
+	}
+	return r, nil
+}
+
+// Remaining returns the number of recorded pairs which have not yet been replayed.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for _, p := range r.pairs {
+		if !p.used {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	form, err := formValues(req)
+	if err != nil {
+		return nil, err
+	}
+
+	exact := func(p *pair) bool { return p.matches(req, form) }
+	samePath := func(p *pair) bool { return p.method == req.Method && p.url.Path == req.URL.Path }
+
+	r.mu.Lock()
+	match := r.firstUnused(exact)
+	if match == nil {
+		match = r.firstUnused(samePath)
+	}
+	if match == nil {
+		match = r.last(exact)
+	}
+	if match == nil {
+		match = r.last(samePath)
+	}
+	if match != nil {
+		match.used = true
+	}
+	r.mu.Unlock()
+
+	if match == nil {
+		return nil, fmt.Errorf("recorder: no recorded response for %v %v", req.Method, req.URL)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(match.response)), req)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: unable to parse recorded response: %v", err)
+	}
+	return resp, nil
+}
+
+// firstUnused returns the first pair which has not been served and satisfies fn, if any
+func (r *Replayer) firstUnused(fn func(*pair) bool) *pair {
+	for _, p := range r.pairs {
+		if !p.used && fn(p) {
+			return p
+		}
+	}
+	return nil
+}
+
+// last returns the last pair which satisfies fn, if any
+func (r *Replayer) last(fn func(*pair) bool) *pair {
+	for i := len(r.pairs) - 1; i >= 0; i-- {
+		if fn(r.pairs[i]) {
+			return r.pairs[i]
+		}
+	}
+	return nil
+}
+
+func (p *pair) matches(req *http.Request, form url.Values) bool {
+	if p.method != req.Method || p.url.Path != req.URL.Path || p.url.RawQuery != req.URL.RawQuery {
+		return false
+	}
+	if len(p.form) != len(form) {
+		return false
+	}
+	for name, values := range p.form {
+		if strings.Join(values, "\x00") != strings.Join(form[name], "\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+// formValues returns the values of a form post, excluding the hidden state fields ASP.NET rotates on each visit. The
+// request body is left intact.
+func formValues(req *http.Request) (url.Values, error) {
+	if req.Body == nil || req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	for name := range values {
+		if strings.HasPrefix(name, "__") {
+			delete(values, name)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,147 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
+)
+
+type session struct {
+	schedule   *greatriverenergy.Schedule
+	shedCounts *greatriverenergy.ShedCounts
+	history    *greatriverenergy.History
+}
+
+func run(t *testing.T, c *greatriverenergy.Client) session {
+	t.Helper()
+	ctx := context.Background()
+
+	var s session
+	var err error
+	if s.schedule, err = c.Schedule(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.shedCounts, err = c.ShedCounts(ctx); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	if s.history, err = c.History(ctx, greatriverenergy.HistoryTypeCI, start, start.AddDate(0, 0, 20)); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	server := lmguidetest.NewServer()
+
+	recorder, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := run(t, greatriverenergy.NewClient(recorder, greatriverenergy.WithBaseURL(server.URL)))
+
+	// Replay with the server gone
+	server.Close()
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayer.Remaining(); got != 4 {
+		t.Errorf("recorded %v pairs, want 4", got)
+	}
+
+	replayed := run(t, greatriverenergy.NewClient(replayer, greatriverenergy.WithBaseURL(server.URL)))
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Errorf("replayed session differs:\nrecorded %+v\nreplayed %+v", recorded, replayed)
+	}
+	if got := replayer.Remaining(); got != 0 {
+		t.Errorf("%v pairs were not replayed", got)
+	}
+
+	// Once the recording is exhausted, the last response for each request keeps being served
+	again := run(t, greatriverenergy.NewClient(replayer, greatriverenergy.WithBaseURL(server.URL)))
+	if !reflect.DeepEqual(recorded, again) {
+		t.Errorf("replayed session differs:\nrecorded %+v\nreplayed %+v", recorded, again)
+	}
+
+	// Pages which were never recorded are not
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/Other.aspx", nil)
+	if _, err := replayer.RoundTrip(req); err == nil {
+		t.Error("expected an error for a page which was not recorded")
+	}
+}
+
+func TestRecorder_Continue(t *testing.T) {
+	dir := t.TempDir()
+	server := lmguidetest.NewServer()
+	defer server.Close()
+
+	// Record into the same directory twice
+	for i := 0; i < 2; i++ {
+		recorder, err := NewRecorder(dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		run(t, greatriverenergy.NewClient(recorder, greatriverenergy.WithBaseURL(server.URL)))
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayer.Remaining(); got != 8 {
+		t.Errorf("recorded %v pairs, want 8", got)
+	}
+}
+
+func TestReplayer_Order(t *testing.T) {
+	dir := t.TempDir()
+	// 10000 sorts before 9999 by name
+	for serial, body := range map[int]string{9999: "first", 10000: "second"} {
+		prefix := filepath.Join(dir, fmt.Sprintf("%04d", serial))
+		request := "GET /Default.aspx HTTP/1.1\r\nHost: lmguide.grenergy.com\r\n\r\n"
+		response := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %v\r\n\r\n%v", len(body), body)
+		if err := os.WriteFile(prefix+"-request.http", []byte(request), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(prefix+"-response.http", []byte(response), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"first", "second", "second"} {
+		req, _ := http.NewRequest(http.MethodGet, "https://lmguide.grenergy.com/Default.aspx", nil)
+		resp, err := replayer.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Errorf("replayed %q, want %q", body, want)
+		}
+	}
+
+	// A new recording continues after the highest serial
+	recorder, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorder.serial != 10000 {
+		t.Errorf("serial = %v, want 10000", recorder.serial)
+	}
+}
@@ -0,0 +1,208 @@
+package greatriverenergy
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how a Client retries requests which fail for reasons which may be transient: transport errors,
+// 5xx responses and 429 Too Many Requests.
+type RetryPolicy struct {
+	// The total number of attempts to make for each request. Defaults to 3.
+	Attempts int
+	// The delay before the first retry, which doubles with each subsequent retry. Each delay is chosen at random between
+	// zero and this value, so that clients don't retry in lockstep. Defaults to 500ms.
+	BaseDelay time.Duration
+	// The longest delay before a retry. A Retry-After header asking for longer than this ends retries. Defaults to 30s.
+	MaxDelay time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.Attempts < 1 {
+		p.Attempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 30 * time.Second
+	}
+	return p
+}
+
+// WithRetries makes the Client retry requests which fail for reasons which may be transient, waiting between attempts
+// as directed by any Retry-After header. By default, requests are attempted only once.
+func WithRetries(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy.withDefaults()
+	}
+}
+
+// retryable returns whether a request which failed with err might succeed if tried again
+func retryable(err error) bool {
+	var statusErr *StatusError
+	switch {
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	default:
+		return true
+	}
+}
+
+// retryDelay returns how long to wait before the next attempt, given the delay doubled for each previous attempt, or
+// false if the site asked for more time than the policy allows
+func (p RetryPolicy) retryDelay(err error, delay time.Duration) (time.Duration, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter, statusErr.RetryAfter <= p.MaxDelay
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1)), true
+}
+
+// parseRetryAfter returns the delay requested by a Retry-After header, which is either a number of seconds or a date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// ErrCircuitOpen is returned for requests which were not attempted because a CircuitBreaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed allows requests
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen allows a single trial request, whose outcome closes or reopens the breaker
+	BreakerHalfOpen
+	// BreakerOpen fails requests without attempting them
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "BreakerState(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// CircuitBreaker stops requests to the site after several consecutive failures, e.g. during an outage or maintenance,
+// so that clients don't keep hammering it. Once a cooldown has passed, a single request is allowed through to see if
+// the site has recovered.
+//
+// A CircuitBreaker is safe for concurrent use, and is usually shared by every Client talking to the same site.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	// Whether the breaker has opened and not yet closed
+	open bool
+	// Whether a trial request is in progress
+	trial bool
+}
+
+// NewCircuitBreaker returns a CircuitBreaker which opens after threshold consecutive failed requests, and which waits
+// for cooldown, or for as long as a Retry-After header asks, before trying again.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// WithCircuitBreaker makes the Client consult a CircuitBreaker before every request.
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(c *Client) {
+		c.breaker = breaker
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state()
+}
+
+func (b *CircuitBreaker) state() BreakerState {
+	switch {
+	case !b.open:
+		return BreakerClosed
+	case b.trial || time.Now().Before(b.openUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+// allow returns ErrCircuitOpen if a request must not be attempted
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state() {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		b.trial = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a request it allowed
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+
+	// Only failures which suggest the site is unwell count; a cancelled request says nothing either way
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return
+	}
+	if err == nil || !retryable(err) {
+		b.failures = 0
+		b.open = false
+		return
+	}
+
+	b.failures++
+	if b.open || b.failures >= b.threshold {
+		cooldown := b.cooldown
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > cooldown {
+			cooldown = statusErr.RetryAfter
+		}
+		b.open = true
+		b.openUntil = time.Now().Add(cooldown)
+	}
+}
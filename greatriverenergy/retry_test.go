@@ -0,0 +1,136 @@
+package greatriverenergy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedTransport answers requests with a sequence of statuses, then passes them through. A status of 0 is a
+// transport error.
+type scriptedTransport struct {
+	mu         sync.Mutex
+	statuses   []int
+	retryAfter string
+	requests   int
+}
+
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	s.requests++
+	var status int
+	scripted := len(s.statuses) > 0
+	if scripted {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	s.mu.Unlock()
+
+	switch {
+	case !scripted:
+		return http.DefaultTransport.RoundTrip(req)
+	case status == 0:
+		return nil, errors.New("connection reset by peer")
+	default:
+		header := make(http.Header)
+		if s.retryAfter != "" {
+			header.Set("Retry-After", s.retryAfter)
+		}
+		return &http.Response{StatusCode: status, Header: header, Body: http.NoBody, Request: req}, nil
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	_, server := newTestClient(t)
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond}
+
+	for _, tc := range []struct {
+		statuses     []int
+		retryAfter   string
+		wantRequests int
+		wantStatus   int
+	}{
+		{[]int{503, 0}, "", 3, 0},
+		{[]int{429, 503, 502}, "", 3, 502},
+		{[]int{404}, "", 1, 404},
+		{[]int{503}, "1", 2, 0},
+		{[]int{503}, "3600", 1, 503},
+	} {
+		rt := &scriptedTransport{statuses: tc.statuses, retryAfter: tc.retryAfter}
+		c := NewClient(rt, WithBaseURL(server.URL), WithRetries(policy))
+
+		_, err := c.Schedule(context.Background())
+		var statusErr *StatusError
+		switch {
+		case tc.wantStatus == 0 && err != nil:
+			t.Errorf("%v: Schedule() = %v", tc.statuses, err)
+		case tc.wantStatus != 0 && (!errors.As(err, &statusErr) || statusErr.StatusCode != tc.wantStatus):
+			t.Errorf("%v: Schedule() = %v, want status %v", tc.statuses, err, tc.wantStatus)
+		}
+		if rt.requests != tc.wantRequests {
+			t.Errorf("%v: made %v requests, want %v", tc.statuses, rt.requests, tc.wantRequests)
+		}
+	}
+}
+
+func TestWithRetries_History(t *testing.T) {
+	_, server := newTestClient(t)
+	c := NewClient(&flakyTransport{failures: 2}, WithBaseURL(server.URL), WithRetries(RetryPolicy{BaseDelay: time.Millisecond}))
+
+	// The form post must be sent again in full
+	history, err := c.History(context.Background(), HistoryTypeCI, ymd(2022, 7, 1), ymd(2022, 7, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Events) == 0 {
+		t.Error("expected events")
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	_, server := newTestClient(t)
+	breaker := NewCircuitBreaker(2, 50*time.Millisecond)
+	rt := &scriptedTransport{statuses: []int{503, 503, 503}}
+	c := NewClient(rt, WithBaseURL(server.URL), WithCircuitBreaker(breaker))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Schedule(ctx); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+	if got := breaker.State(); got != BreakerOpen {
+		t.Fatalf("State() = %v after failures, want open", got)
+	}
+
+	// Requests fail without being attempted while open
+	if _, err := c.ShedCounts(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("ShedCounts() = %v, want ErrCircuitOpen", err)
+	}
+	if rt.requests != 2 {
+		t.Errorf("made %v requests, want 2", rt.requests)
+	}
+
+	// After the cooldown, a failed trial reopens the breaker
+	time.Sleep(60 * time.Millisecond)
+	if got := breaker.State(); got != BreakerHalfOpen {
+		t.Fatalf("State() = %v after cooldown, want half-open", got)
+	}
+	if _, err := c.Schedule(ctx); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Schedule() = %v, want a failed trial", err)
+	}
+	if got := breaker.State(); got != BreakerOpen {
+		t.Fatalf("State() = %v after failed trial, want open", got)
+	}
+
+	// A successful trial closes it
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.Schedule(ctx); err != nil {
+		t.Errorf("Schedule() = %v", err)
+	}
+	if got := breaker.State(); got != BreakerClosed {
+		t.Errorf("State() = %v after successful trial, want closed", got)
+	}
+}
@@ -3,7 +3,6 @@ package greatriverenergy
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -13,17 +12,26 @@ import (
 
 type Schedule struct {
 	ConservationGauge ConservationStatus `json:"conservationGauge"`
-	Today             []ProgramSchedule  `json:"today"`
-	NextDay           []ProgramSchedule  `json:"nextDay"`
-	LastUpdated       time.Time          `json:"lastUpdated"`
+	// The src of the gauge image, which is kept even if ConservationGauge could not be determined
+	ConservationGaugeSrc string            `json:"conservationGaugeSrc"`
+	Today                []ProgramSchedule `json:"today"`
+	NextDay              []ProgramSchedule `json:"nextDay"`
+	LastUpdated          time.Time         `json:"lastUpdated"`
+	Warnings             []Warning         `json:"warnings,omitempty"`
 }
 
 type ProgramSchedule struct {
-	Class             string      `json:"class"`
-	ProgramType       string      `json:"programType"`
+	Class       string `json:"class"`
+	ProgramType string `json:"programType"`
+	// The day to which this forecast applies, at midnight Central time
+	Date              time.Time   `json:"date"`
 	Probability       Probability `json:"probability"`
 	ExpectedStartTime time.Time   `json:"expectedStartTime,omitempty"`
 	ExpectedEndTime   time.Time   `json:"expectedEndTime,omitempty"`
+
+	// The probability and expected time exactly as displayed, which are kept even if they could not be parsed
+	RawProbability  string `json:"rawProbability"`
+	RawExpectedTime string `json:"rawExpectedTime"`
 }
 
 type ConservationStatus int
@@ -77,29 +85,25 @@ func (p Probability) String() string {
 const ClassCI = "CI"
 const ClassR = "Residential"
 
-func (c Client) Schedule(ctx context.Context) (*Schedule, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://lmguide.grenergy.com/Default.aspx", nil)
-	if err != nil {
-		return nil, err
-	}
+const schedulePage = "Default.aspx"
 
-	resp, err := c.client.Do(req)
+func (c Client) Schedule(ctx context.Context) (*Schedule, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.pageURL(schedulePage), nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("expected status code 200, got %v", resp.StatusCode)
-	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := c.do(req, schedulePage)
 	if err != nil {
 		return nil, err
 	}
 
+	p := c.newParser()
 	var conservationGauge ConservationStatus
 
-	conservationGaugeImgSrc := doc.Find("img#ContentPlaceHolder1_Gauge_Image").AttrOr("src", "")
+	const gaugeSelector = "img#ContentPlaceHolder1_Gauge_Image"
+	gauge := doc.Find(gaugeSelector)
+	conservationGaugeImgSrc := gauge.AttrOr("src", "")
 	switch conservationGaugeImgSrc {
 	case "images/gauge1.jpg":
 		conservationGauge = ConservationStatusNormalUsage
@@ -110,56 +114,90 @@ func (c Client) Schedule(ctx context.Context) (*Schedule, error) {
 	case "images/gauge4.jpg":
 		conservationGauge = ConservationStatusCriticalUsage
 	default:
-		return nil, fmt.Errorf("scrape failure: unable to determine conservation status (src=%q)", conservationGaugeImgSrc)
+		if err := p.fail(scrapeError(schedulePage, "conservation_gauge", gaugeSelector, gauge, conservationGaugeImgSrc,
+			fmt.Errorf("unable to determine conservation status"))); err != nil {
+			return nil, err
+		}
 	}
 
 	var today time.Time
-	dateTime := doc.Find("#ContentPlaceHolder2_DateTime_Label").Text()
-	if dateTime, found := strings.CutSuffix(dateTime, " CPT"); !found {
-		return nil, fmt.Errorf("current date/time did not end in \"CPT\": %q", dateTime)
-	} else if today, err = time.ParseInLocation("Mon Jan _2, 2006 - 03:04 PM", dateTime, tz); err != nil {
-		return nil, fmt.Errorf("failed to parse date/time %q: %v", dateTime, err)
+	const dateTimeSelector = "#ContentPlaceHolder2_DateTime_Label"
+	dateTimeLabel := doc.Find(dateTimeSelector)
+	if dateTime, found := strings.CutSuffix(dateTimeLabel.Text(), " CPT"); !found {
+		err = p.fail(scrapeError(schedulePage, "date_time", dateTimeSelector, dateTimeLabel, dateTime,
+			fmt.Errorf("current date/time did not end in \"CPT\"")))
+	} else if wall, parseErr := parseWallClock("Mon Jan _2, 2006 - 03:04 PM", dateTime); parseErr != nil {
+		err = p.fail(scrapeError(schedulePage, "date_time", dateTimeSelector, dateTimeLabel, dateTime, parseErr))
+	} else {
+		// "CPT" doesn't say whether 1:30 AM is before or after the clocks go back, but the Date header does
+		today = nearestLocalTime(wall, doc.date)
+	}
+	if err != nil {
+		return nil, err
+	} else if today.IsZero() {
+		// Tolerating an unparseable date; assume the schedule is for the current day
+		today = c.now().In(tz)
 	}
 	nextDay := today.AddDate(0, 0, 1)
 
-	todaySchedule, err := parseScheduleTable(doc.Find("#ContentPlaceHolder2_TodaySched_Table"), today)
+	todaySchedule, err := p.parseScheduleTable(doc.Document, "#ContentPlaceHolder2_TodaySched_Table", today)
 	if err != nil {
-		return nil, fmt.Errorf("scrape failure: failed to parse Today table: %v", err)
+		return nil, err
 	}
 
-	nextDaySchedule, err := parseScheduleTable(doc.Find("#ContentPlaceHolder2_NextDaySched_Table"), nextDay)
+	nextDaySchedule, err := p.parseScheduleTable(doc.Document, "#ContentPlaceHolder2_NextDaySched_Table", nextDay)
 	if err != nil {
-		return nil, fmt.Errorf("scrape failure: failed to parse Next Day table: %v", err)
+		return nil, err
 	}
 
 	var lastUpdated time.Time
-	if lastUpdatedStr, found := strings.CutSuffix(doc.Find("#ContentPlaceHolder2_LastUdpated_Label").Text(), " CPT"); !found {
-		return nil, fmt.Errorf("last updated date/time did not end in \"CPT\": %q", lastUpdatedStr)
-	} else if lastUpdated, err = time.ParseInLocation("01/02/2006 03:04 PM", lastUpdatedStr, tz); err != nil {
-		return nil, fmt.Errorf("failed to parse last updated time: %v", err)
+	const lastUpdatedSelector = "#ContentPlaceHolder2_LastUdpated_Label"
+	lastUpdatedLabel := doc.Find(lastUpdatedSelector)
+	if lastUpdatedStr, found := strings.CutSuffix(lastUpdatedLabel.Text(), " CPT"); !found {
+		err = p.fail(scrapeError(schedulePage, "last_updated", lastUpdatedSelector, lastUpdatedLabel, lastUpdatedStr,
+			fmt.Errorf("last updated date/time did not end in \"CPT\"")))
+	} else if wall, parseErr := parseWallClock("01/02/2006 03:04 PM", lastUpdatedStr); parseErr != nil {
+		err = p.fail(scrapeError(schedulePage, "last_updated", lastUpdatedSelector, lastUpdatedLabel, lastUpdatedStr, parseErr))
+	} else {
+		// The schedule can't have been updated after it was sent
+		lastUpdated = latestLocalTime(wall, doc.date)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return &Schedule{
-		ConservationGauge: conservationGauge,
-		Today:             todaySchedule,
-		NextDay:           nextDaySchedule,
-		LastUpdated:       lastUpdated,
+		ConservationGauge:    conservationGauge,
+		ConservationGaugeSrc: conservationGaugeImgSrc,
+		Today:                todaySchedule,
+		NextDay:              nextDaySchedule,
+		LastUpdated:          lastUpdated,
+		Warnings:             p.warnings,
 	}, nil
 }
 
-func parseScheduleTable(table *goquery.Selection, day time.Time) ([]ProgramSchedule, error) {
+func (p *parser) parseScheduleTable(doc *goquery.Document, tableSelector string, day time.Time) ([]ProgramSchedule, error) {
+	table := doc.Find(tableSelector)
+	if table.Length() == 0 {
+		// No schedule for this day
+		return nil, nil
+	}
+
+	columns, err := p.mapColumns(schedulePage, tableSelector, table, scheduleLayout)
+	if err != nil {
+		return nil, err
+	}
+
 	var out []ProgramSchedule
-	var err error
+	rowSelector := tableSelector + " .BodyText_noSpaces"
 	table.Find(".BodyText_noSpaces").Each(func(_ int, tr *goquery.Selection) {
 		if err != nil {
 			return
 		}
 
-		cells := tr.Find("td").Map(func(_ int, td *goquery.Selection) string {
-			return td.Text()
-		})
-		if len(cells) != 4 {
-			err = fmt.Errorf("expected 4 cells, got %v", len(cells))
+		cells, rowErr := columns.cells(schedulePage, rowSelector, tr)
+		if rowErr != nil {
+			err = p.fail(rowErr)
 			return
 		}
 
@@ -174,37 +212,49 @@ func parseScheduleTable(table *goquery.Selection, day time.Time) ([]ProgramSched
 		case "Scheduled":
 			probability = ProbabilityScheduled
 		default:
-			err = fmt.Errorf("unrecognized probability: %q", probability)
+			// Keep the row, leaving the probability unknown
+			if err = p.fail(scrapeError(schedulePage, "probability", rowSelector, tr, cells[2],
+				fmt.Errorf("unrecognized probability"))); err != nil {
+				return
+			}
 		}
 
 		var startAt, endAt time.Time
 		if cells[3] == "Undetermined" {
 			// zero value is correct
 		} else if parts := strings.Split(cells[3], " - "); len(parts) == 2 {
+			// An ambiguous start is taken to be the first time the clock shows it, and the end to be the first time the
+			// clock shows it afterwards. A range like "08:00 PM - 02:00 AM" therefore ends the following day.
 			ymd := day.Format("2006-01-02 ")
-			start, err := time.ParseInLocation("2006-01-02 03:04 PM", ymd+parts[0], day.Location())
-			if err == nil {
-				startAt = start
+			start, parseErr := parseWallClock("2006-01-02 03:04 PM", ymd+parts[0])
+			if parseErr == nil {
+				startAt = localTimes(start)[0]
 			} else {
-				log.Printf("warning: failed to parse start time %q", parts[0])
+				p.warn(scrapeError(schedulePage, "expected_start_time", rowSelector, tr, parts[0], parseErr))
 			}
 
-			end, err := time.ParseInLocation("2006-01-02 03:04 PM", ymd+parts[1], day.Location())
-			if err == nil {
-				endAt = end
+			end, parseErr := parseWallClock("2006-01-02 03:04 PM", ymd+parts[1])
+			if parseErr != nil {
+				p.warn(scrapeError(schedulePage, "expected_end_time", rowSelector, tr, parts[1], parseErr))
+			} else if !startAt.IsZero() {
+				endAt = localTimesFrom(end, startAt)[0]
 			} else {
-				log.Printf("warning: failed to parse end time %q", parts[1])
+				endAt = localTimes(end)[0]
 			}
 		} else {
-			log.Printf("warning: failed to parse time %q", cells[3])
+			p.warn(scrapeError(schedulePage, "expected_time", rowSelector, tr, cells[3],
+				fmt.Errorf("expected a time range")))
 		}
 
 		out = append(out, ProgramSchedule{
 			Class:             cells[0],
 			ProgramType:       cells[1],
+			Date:              toMidnight(day),
 			Probability:       probability,
 			ExpectedStartTime: startAt,
 			ExpectedEndTime:   endAt,
+			RawProbability:    cells[2],
+			RawExpectedTime:   cells[3],
 		})
 	})
 
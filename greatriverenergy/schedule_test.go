@@ -1,13 +1,17 @@
 package greatriverenergy
 
 import (
+	"bytes"
 	"context"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
 )
 
 func TestClient_Schedule(t *testing.T) {
-	c := NewClient(nil)
+	c, _ := newTestClient(t)
 	schedule, err := c.Schedule(context.Background())
 	if err != nil {
 		t.Fatal(err)
@@ -25,16 +29,22 @@ func TestClient_Schedule(t *testing.T) {
 		{ClassR, "Interruptible Water Heating"},
 	}
 
-	for key, programs := range map[string][]ProgramSchedule{
-		"Today":   schedule.Today,
-		"NextDay": schedule.NextDay,
+	for key, day := range map[string]struct {
+		programs []ProgramSchedule
+		date     time.Time
+	}{
+		"Today":   {schedule.Today, ymd(2023, 7, 8)},
+		"NextDay": {schedule.NextDay, ymd(2023, 7, 9)},
 	} {
 		var gotPrograms [][]string
-		for _, program := range programs {
+		for _, program := range day.programs {
 			gotPrograms = append(gotPrograms, []string{
 				program.Class,
 				program.ProgramType,
 			})
+			if !program.Date.Equal(day.date) {
+				t.Errorf("%s %s Date = %v, want %v", key, program.ProgramType, program.Date, day.date)
+			}
 		}
 
 		if !reflect.DeepEqual(gotPrograms, wantPrograms) {
@@ -42,3 +52,64 @@ func TestClient_Schedule(t *testing.T) {
 		}
 	}
 }
+
+func TestClient_Schedule_Overnight(t *testing.T) {
+	c, server := newTestClient(t)
+	server.SetPage("Default.aspx", bytes.Replace(lmguidetest.Fixture("Default.aspx"),
+		[]byte("<td>Possible</td><td>03:00 PM - 07:00 PM</td>"), []byte("<td>Scheduled</td><td>08:00 PM - 02:00 AM</td>"), 1))
+
+	schedule, err := c.Schedule(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	irrigation := schedule.NextDay[2]
+	if irrigation.ExpectedStartTime != ymdhm(2023, 7, 9, 20, 0) || irrigation.ExpectedEndTime != ymdhm(2023, 7, 10, 2, 0) {
+		t.Errorf("expected 2023-07-09 20:00 to 2023-07-10 02:00, got %v to %v", irrigation.ExpectedStartTime, irrigation.ExpectedEndTime)
+	}
+	if len(schedule.Warnings) != 0 {
+		t.Errorf("Warnings = %v", schedule.Warnings)
+	}
+}
+
+func TestClient_Schedule_DaylightSaving(t *testing.T) {
+	c, server := newTestClient(t)
+	page := lmguidetest.Fixture("Default.aspx")
+	// November 5, 2023: the clocks go back from 02:00 to 01:00, so the page's times are ambiguous
+	page = bytes.Replace(page, []byte("Sat Jul  8, 2023 - 11:05 AM"), []byte("Sun Nov  5, 2023 - 01:35 AM"), 1)
+	page = bytes.Replace(page, []byte("07/08/2023 09:30 AM"), []byte("11/05/2023 01:30 AM"), 1)
+	// March 12, 2023 is the following day for the next schedule, where the clocks go forward from 02:00 to 03:00
+	page = bytes.Replace(page, []byte("<td>Possible</td><td>03:00 PM - 07:00 PM</td>"), []byte("<td>Scheduled</td><td>01:00 AM - 04:00 AM</td>"), 1)
+	server.SetPage("Default.aspx", bytes.Replace(page, []byte("Sun Nov  5, 2023"), []byte("Sat Mar 11, 2023"), 1))
+	server.SetDate(utc(2023, 3, 11, 7, 36))
+
+	schedule, err := c.Schedule(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	irrigation := schedule.NextDay[2]
+	if !irrigation.ExpectedStartTime.Equal(utc(2023, 3, 12, 7, 0)) || !irrigation.ExpectedEndTime.Equal(utc(2023, 3, 12, 9, 0)) {
+		t.Errorf("expected 2 hours from 2023-03-12 07:00 UTC, got %v to %v", irrigation.ExpectedStartTime.UTC(), irrigation.ExpectedEndTime.UTC())
+	}
+
+	// The Date header says which 01:30 the schedule was last updated
+	server.SetPage("Default.aspx", page)
+	for _, test := range []struct {
+		date        time.Time
+		lastUpdated time.Time
+	}{
+		{utc(2023, 11, 5, 6, 36), utc(2023, 11, 5, 6, 30)},
+		{utc(2023, 11, 5, 7, 36), utc(2023, 11, 5, 7, 30)},
+		// The schedule was updated before the clocks went back
+		{utc(2023, 11, 5, 7, 29), utc(2023, 11, 5, 6, 30)},
+	} {
+		server.SetDate(test.date)
+		schedule, err := c.Schedule(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !schedule.LastUpdated.Equal(test.lastUpdated) {
+			t.Errorf("with Date %v, LastUpdated = %v, want %v", test.date, schedule.LastUpdated.UTC(), test.lastUpdated)
+		}
+	}
+}
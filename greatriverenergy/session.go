@@ -0,0 +1,98 @@
+package greatriverenergy
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// session is an isolated conversation with the site. History retrieval is stateful: the site tracks each visitor with
+// a session cookie, and every response carries a ViewState which the next form submission must present. A session
+// holds both, so that concurrent operations on one Client don't interfere with each other.
+type session struct {
+	jar http.CookieJar
+	// The history form from the most recent response, if any, which can be submitted without loading it again
+	form *goquery.Selection
+	// When the session was last used
+	lastUsed time.Time
+}
+
+func newSession() *session {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		panic(err)
+	}
+	return &session{jar: jar}
+}
+
+// warm returns whether the session can submit the history form without loading it first
+func (s *session) warm() bool {
+	return s.form != nil
+}
+
+// inSession returns a copy of the Client which makes requests as part of a session
+func (c Client) inSession(s *session) Client {
+	c.client.Jar = s.jar
+	return c
+}
+
+// sessionPool holds idle warm sessions for reuse
+type sessionPool struct {
+	size        int
+	idleTimeout time.Duration
+
+	mu   sync.Mutex
+	idle []*session
+}
+
+// WithSessionPool makes the Client keep up to size sessions which have already loaded the history form, so that
+// subsequent History calls can skip loading it. A size of 0 keeps every session, which is as many as were ever in use
+// at once. Sessions idle for longer than idleTimeout are discarded, since the site forgets them; an idleTimeout of 0
+// means 10 minutes.
+//
+// The pool belongs to the Option, so every Client created with the same Option shares it. Without a pool, each History
+// call starts a new session.
+func WithSessionPool(size int, idleTimeout time.Duration) Option {
+	if idleTimeout <= 0 {
+		idleTimeout = 10 * time.Minute
+	}
+	pool := &sessionPool{size: size, idleTimeout: idleTimeout}
+	return func(c *Client) {
+		c.sessions = pool
+	}
+}
+
+// get returns the most recently used idle session which has not expired, or a new session
+func (p *sessionPool) get() *session {
+	if p == nil {
+		return newSession()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.idle) > 0 {
+		s := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if time.Since(s.lastUsed) < p.idleTimeout {
+			return s
+		}
+	}
+	return newSession()
+}
+
+// put returns a session to the pool, if it is warm and there is room
+func (p *sessionPool) put(s *session) {
+	if p == nil || !s.warm() {
+		return
+	}
+	s.lastUsed = time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.size <= 0 || len(p.idle) < p.size {
+		p.idle = append(p.idle, s)
+	}
+}
@@ -0,0 +1,173 @@
+package greatriverenergy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestClient_History_Concurrent(t *testing.T) {
+	_, server := newTestClient(t)
+	c := NewClient(nil, WithBaseURL(server.URL))
+	ctx := context.Background()
+
+	want := make(map[HistoryType]int)
+	for _, historyType := range []HistoryType{HistoryTypeR, HistoryTypeCI} {
+		history, err := c.History(ctx, historyType, ymd(2022, 7, 1), ymd(2023, 7, 31))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[historyType] = len(history.Events)
+	}
+
+	// One Client, many goroutines, each with a session of its own
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		historyType := []HistoryType{HistoryTypeR, HistoryTypeCI}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			history, err := c.History(ctx, historyType, ymd(2022, 7, 1), ymd(2023, 7, 31))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(history.Events) != want[historyType] {
+				t.Errorf("History(%q) returned %v events, want %v", historyType, len(history.Events), want[historyType])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestWithSessionPool(t *testing.T) {
+	_, server := newTestClient(t)
+	transport := &countingTransport{}
+	c := NewClient(transport, WithBaseURL(server.URL), WithSessionPool(1, time.Minute))
+	ctx := context.Background()
+
+	first, err := c.History(ctx, HistoryTypeCI, ymd(2022, 7, 1), ymd(2023, 7, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.gets != 1 || transport.posts != 1 {
+		t.Errorf("first History() made %v GETs and %v POSTs, want 1 and 1", transport.gets, transport.posts)
+	}
+
+	// A warm session submits the form from the previous response
+	second, err := c.History(ctx, HistoryTypeR, ymd(2022, 7, 1), ymd(2023, 7, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.gets != 1 || transport.posts != 2 {
+		t.Errorf("second History() made %v GETs and %v POSTs, want 0 and 1", transport.gets-1, transport.posts-1)
+	}
+	if len(first.Events) == 0 || len(second.Events) == 0 || first.Events[0].HistoryType == second.Events[0].HistoryType {
+		t.Errorf("unexpected events: %+v, %+v", first.Events, second.Events)
+	}
+
+	// If the site forgets the session, a new one is started
+	server.ExpireSessions()
+	third, err := c.History(ctx, HistoryTypeCI, ymd(2022, 7, 1), ymd(2023, 7, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.gets != 2 || transport.posts != 4 {
+		t.Errorf("third History() made %v GETs and %v POSTs, want 1 and 2", transport.gets-1, transport.posts-2)
+	}
+	if len(third.Events) != len(first.Events) {
+		t.Errorf("third History() returned %v events, want %v", len(third.Events), len(first.Events))
+	}
+}
+
+func TestWithSessionPool_Shared(t *testing.T) {
+	_, server := newTestClient(t)
+	transport := &countingTransport{}
+	pool := WithSessionPool(1, time.Minute)
+	ctx := context.Background()
+
+	// Clients created with the same Option, as for each /history request, share its sessions
+	for i := 0; i < 2; i++ {
+		c := NewClient(transport, WithBaseURL(server.URL), pool)
+		if _, err := c.History(ctx, HistoryTypeCI, ymd(2022, 7, 1), ymd(2023, 7, 31)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if transport.gets != 1 || transport.posts != 2 {
+		t.Errorf("History() made %v GETs and %v POSTs, want 1 and 2", transport.gets, transport.posts)
+	}
+}
+
+func TestWithSessionPool_Expired(t *testing.T) {
+	_, server := newTestClient(t)
+	transport := &countingTransport{}
+	breaker := NewCircuitBreaker(5, time.Minute)
+	c := NewClient(transport, WithBaseURL(server.URL), WithSessionPool(2, time.Minute),
+		WithRetries(RetryPolicy{BaseDelay: time.Millisecond}), WithCircuitBreaker(breaker))
+	ctx := context.Background()
+
+	historyTypes := []HistoryType{HistoryTypeR, HistoryTypeCI}
+	retrieve := func() {
+		var wg sync.WaitGroup
+		for _, historyType := range historyTypes {
+			wg.Add(1)
+			go func(historyType HistoryType) {
+				defer wg.Done()
+				if _, err := c.History(ctx, historyType, ymd(2022, 7, 1), ymd(2023, 7, 31)); err != nil {
+					t.Error(err)
+				}
+			}(historyType)
+		}
+		wg.Wait()
+	}
+
+	// Warm up both sessions, then have the site forget them
+	retrieve()
+	server.ExpireSessions()
+	gets, posts := transport.gets, transport.posts
+
+	// Each forgotten session is tried once, without retrying or opening the breaker, before starting over
+	retrieve()
+	if transport.gets-gets != 2 || transport.posts-posts != 4 {
+		t.Errorf("History() made %v GETs and %v POSTs, want 2 and 4", transport.gets-gets, transport.posts-posts)
+	}
+	if state := breaker.State(); state != BreakerClosed {
+		t.Errorf("breaker is %v, want closed", state)
+	}
+}
+
+func TestSessionPool_IdleTimeout(t *testing.T) {
+	pool := &sessionPool{size: 2, idleTimeout: time.Minute}
+	warm := newSession()
+	warm.form = new(goquery.Selection)
+	pool.put(warm)
+	pool.put(newSession())
+
+	if got := pool.get(); got != warm {
+		t.Error("expected the warm session")
+	}
+	if got := pool.get(); got == warm || got.warm() {
+		t.Error("expected a new session, since only warm sessions are kept")
+	}
+
+	pool.put(warm)
+	warm.lastUsed = time.Now().Add(-2 * time.Minute)
+	if got := pool.get(); got == warm {
+		t.Error("expected the expired session to be discarded")
+	}
+}
+
+func TestSessionPool_Unlimited(t *testing.T) {
+	pool := &sessionPool{idleTimeout: time.Minute}
+	for i := 0; i < 3; i++ {
+		s := newSession()
+		s.form = new(goquery.Selection)
+		pool.put(s)
+	}
+	if len(pool.idle) != 3 {
+		t.Errorf("kept %v sessions, want 3", len(pool.idle))
+	}
+}
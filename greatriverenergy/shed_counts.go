@@ -15,66 +15,93 @@ type ShedCounts struct {
 
 	// The ymd on which the counts were reset
 	LastResetOn time.Time
+
+	Warnings []Warning
 }
 
+const shedCountsPage = "ShedCount.aspx"
+
 func (c *Client) ShedCounts(ctx context.Context) (*ShedCounts, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://lmguide.grenergy.com/ShedCount.aspx", nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.pageURL(shedCountsPage), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.client.Do(req)
+	doc, err := c.do(req, shedCountsPage)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("expected status code 200, got %v", resp.StatusCode)
+
+	p := c.newParser()
+	table := make(map[string]int)
+
+	const tableSelector = "#ContentPlaceHolder2_ShedCounts_Table"
+	tableSelection := doc.Find(tableSelector)
+	if tableSelection.Length() == 0 {
+		// Without the table there are no counts, but tolerant parsing still reports the reset date
+		err = p.fail(scrapeError(shedCountsPage, "shed_counts_table", tableSelector, doc.Find("form#form1"), "",
+			fmt.Errorf("unable to find shed counts table")))
+	} else {
+		err = p.parseShedCountsTable(tableSelection, tableSelector, table)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	var parsedResetCount time.Time
+	const resetSelector = "#ContentPlaceHolder2_ShedCountReset_Label"
+	resetLabel := doc.Find(resetSelector)
+	if resetCount := resetLabel.Text(); resetCount == "" {
+		err = p.fail(scrapeError(shedCountsPage, "reset_date", resetSelector, doc.Find("form#form1"), "",
+			fmt.Errorf("unable to find reset count")))
+	} else if parsedResetCount, err = time.ParseInLocation("01/02/2006", resetCount, tz); err != nil {
+		err = p.fail(scrapeError(shedCountsPage, "reset_date", resetSelector, resetLabel, resetCount, err))
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	table := make(map[string]int)
+	return &ShedCounts{
+		Table:       table,
+		LastResetOn: parsedResetCount,
+		Warnings:    p.warnings,
+	}, nil
+}
+
+// parseShedCountsTable adds the count in each row of the table to counts
+func (p *parser) parseShedCountsTable(tableSelection *goquery.Selection, tableSelector string, counts map[string]int) error {
+	rowSelector := tableSelector + " tr.BodyText_noSpaces"
+
+	columns, err := p.mapColumns(shedCountsPage, tableSelector, tableSelection, shedCountsLayout)
+	if err != nil {
+		return err
+	}
 
-	doc.Find("#ContentPlaceHolder2_ShedCounts_Table tr.BodyText_noSpaces").Each(func(_ int, selection *goquery.Selection) {
+	tableSelection.Find("tr.BodyText_noSpaces").Each(func(_ int, selection *goquery.Selection) {
 		if err != nil {
 			return
 		}
 
-		cells := selection.Find("td")
-		name := cells.Eq(0).Text()
-		count := cells.Eq(2).Text()
+		cells, rowErr := columns.cells(shedCountsPage, rowSelector, selection)
+		if rowErr != nil {
+			err = p.fail(rowErr)
+			return
+		}
+
+		name, count := cells[0], cells[1]
 		if name == "" || count == "" {
-			err = fmt.Errorf("scrape failure: table cell was empty")
+			err = p.fail(scrapeError(shedCountsPage, "shed_count", rowSelector, selection, "", fmt.Errorf("table cell was empty")))
 			return
 		}
 
 		parsedCount, parseErr := strconv.Atoi(count)
 		if parseErr != nil {
-			err = fmt.Errorf("scrape failure: unable to parse count for %q: %v", name, parseErr)
+			err = p.fail(scrapeError(shedCountsPage, "shed_count", rowSelector, selection, count,
+				fmt.Errorf("unable to parse count for %q: %w", name, parseErr)))
 			return
 		}
 
-		table[name] = parsedCount
+		counts[name] = parsedCount
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	resetCount := doc.Find("#ContentPlaceHolder2_ShedCountReset_Label").Text()
-	if resetCount == "" {
-		return nil, fmt.Errorf("scrape failure: unable to find reset count")
-	}
-	parsedResetCount, err := time.ParseInLocation("01/02/2006", resetCount, tz)
-	if err != nil {
-		return nil, fmt.Errorf("scrape failure: unable to parse reset count: %v", err)
-	}
-
-	return &ShedCounts{
-		Table:       table,
-		LastResetOn: parsedResetCount,
-	}, nil
+	return err
 }
@@ -1,13 +1,17 @@
 package greatriverenergy
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"testing"
 	"time"
+
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/lmguidetest"
 )
 
 func TestClient_ShedCounts(t *testing.T) {
-	c := NewClient(nil)
+	c, _ := newTestClient(t)
 	counts, err := c.ShedCounts(context.Background())
 	if err != nil {
 		t.Fatal(err)
@@ -39,3 +43,27 @@ func TestClient_ShedCounts(t *testing.T) {
 		}
 	}
 }
+
+func TestClient_ShedCounts_MissingTable(t *testing.T) {
+	c, server := newTestClient(t)
+	server.SetPage("ShedCount.aspx", bytes.Replace(lmguidetest.Fixture("ShedCount.aspx"), []byte(`id="ContentPlaceHolder2_ShedCounts_Table"`), []byte(`id="Counts"`), 1))
+
+	_, err := c.ShedCounts(context.Background())
+	var scrapeErr *ScrapeError
+	if !errors.As(err, &scrapeErr) || scrapeErr.Field != "shed_counts_table" {
+		t.Fatalf("expected a ScrapeError for shed_counts_table, got %v", err)
+	}
+
+	// Tolerant parsing warns, and still reports the reset date
+	c = NewClient(nil, WithBaseURL(server.URL), WithTolerantParsing())
+	counts, err := c.ShedCounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts.Table) != 0 || counts.LastResetOn.IsZero() {
+		t.Errorf("ShedCounts() = %+v", counts)
+	}
+	if len(counts.Warnings) != 1 || counts.Warnings[0].Field != "shed_counts_table" {
+		t.Errorf("Warnings = %+v, want one for shed_counts_table", counts.Warnings)
+	}
+}
@@ -16,3 +16,88 @@ func init() {
 		panic(err)
 	}
 }
+
+// The site displays times as they appear on a clock in Central time, which is ambiguous for an hour when daylight
+// saving time ends, and which skips an hour when it begins. Such times are parsed as wall clock readings, i.e. in UTC,
+// and then resolved to the times at which a clock in Chicago would show them.
+
+// parseWallClock parses a wall clock reading without resolving it to a time
+func parseWallClock(layout, value string) (time.Time, error) {
+	return time.Parse(layout, value)
+}
+
+// wallClock returns the reading of a clock in Central time at t
+func wallClock(t time.Time) time.Time {
+	t = t.In(tz)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// localTimes returns the times at which a clock in Central time shows a wall clock reading, earliest first. There are
+// two such times for a reading in the hour repeated when daylight saving time ends. A reading in the hour skipped when
+// it begins is taken to be in standard time, i.e. an hour later by the clock.
+func localTimes(wall time.Time) []time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), tz)
+
+	var out []time.Time
+	for _, candidate := range []time.Time{t.Add(-time.Hour), t, t.Add(time.Hour)} {
+		if wallClock(candidate).Equal(wall) {
+			out = append(out, candidate)
+		}
+	}
+	if len(out) == 0 {
+		// Skipped; use the offset in effect beforehand
+		_, offset := t.Add(-2 * time.Hour).Zone()
+		out = append(out, wall.Add(-time.Duration(offset)*time.Second).In(tz))
+	}
+	return out
+}
+
+// localTimesFrom returns the times at or after t at which a clock in Central time shows a wall clock reading, on the
+// day of the reading if possible, and otherwise on the following day
+func localTimesFrom(wall, t time.Time) []time.Time {
+	for _, day := range []time.Time{wall, wall.AddDate(0, 0, 1)} {
+		var out []time.Time
+		for _, candidate := range localTimes(day) {
+			if !candidate.Before(t) {
+				out = append(out, candidate)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return localTimes(wall.AddDate(0, 0, 1))
+}
+
+// nearestLocalTime returns the time at which a clock in Central time shows a wall clock reading which is nearest to
+// t. If t is zero, it returns the earliest such time.
+func nearestLocalTime(wall, t time.Time) time.Time {
+	candidates := localTimes(wall)
+	nearest := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if !t.IsZero() && absDuration(candidate.Sub(t)) < absDuration(nearest.Sub(t)) {
+			nearest = candidate
+		}
+	}
+	return nearest
+}
+
+// latestLocalTime returns the latest time no later than t at which a clock in Central time shows a wall clock
+// reading. If there is no such time, or if t is zero, it returns the earliest time.
+func latestLocalTime(wall, t time.Time) time.Time {
+	candidates := localTimes(wall)
+	latest := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if !t.IsZero() && !candidate.After(t) {
+			latest = candidate
+		}
+	}
+	return latest
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,49 @@
+package greatriverenergy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// utc returns a time in UTC, which is unambiguous
+func utc(y, m, d, h, min int) time.Time {
+	return time.Date(y, time.Month(m), d, h, min, 0, 0, time.UTC)
+}
+
+func TestLocalTimes(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		wall time.Time
+		want []time.Time
+	}{
+		{"ordinary", utc(2023, 7, 8, 14, 0), []time.Time{utc(2023, 7, 8, 19, 0)}},
+		// The clocks go forward from 02:00 CST to 03:00 CDT, so 02:30 is taken as standard time
+		{"march", utc(2023, 3, 12, 2, 30), []time.Time{utc(2023, 3, 12, 8, 30)}},
+		{"march after", utc(2023, 3, 12, 3, 30), []time.Time{utc(2023, 3, 12, 8, 30)}},
+		// The clocks go back from 02:00 CDT to 01:00 CST, so 01:30 happens twice
+		{"november", utc(2023, 11, 5, 1, 30), []time.Time{utc(2023, 11, 5, 6, 30), utc(2023, 11, 5, 7, 30)}},
+		{"november before", utc(2023, 11, 5, 0, 30), []time.Time{utc(2023, 11, 5, 5, 30)}},
+		{"november after", utc(2023, 11, 5, 2, 30), []time.Time{utc(2023, 11, 5, 8, 30)}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := localTimes(test.wall)
+			for i := range got {
+				got[i] = got[i].UTC()
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("localTimes(%v) = %v, want %v", test.wall, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLocalTimesFrom(t *testing.T) {
+	wall := utc(2023, 11, 5, 1, 30)
+	if got := localTimesFrom(wall, utc(2023, 11, 5, 7, 0)); len(got) != 1 || !got[0].Equal(utc(2023, 11, 5, 7, 30)) {
+		t.Errorf("localTimesFrom() = %v, want the second 01:30", got)
+	}
+	if got := localTimesFrom(wall, utc(2023, 11, 5, 8, 0)); len(got) != 1 || !got[0].Equal(utc(2023, 11, 6, 7, 30)) {
+		t.Errorf("localTimesFrom() = %v, want 01:30 the following day", got)
+	}
+}
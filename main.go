@@ -1,28 +1,164 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy"
 	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/exporter"
+	"github.com/willglynn/greatriverenergy_exporter/greatriverenergy/recorder"
 )
 
 func main() {
-	rt := http.DefaultTransport
+	var rt http.RoundTripper = http.DefaultTransport
+	if dir := os.Getenv("REPLAY_DIR"); dir != "" {
+		replayer, err := recorder.NewReplayer(dir)
+		if err != nil {
+			log.Fatalf("Error loading recording: %v", err)
+		}
+		log.Printf("Replaying recorded requests from %v", dir)
+		rt = replayer
+	} else if dir := os.Getenv("RECORD_DIR"); dir != "" {
+		rec, err := recorder.NewRecorder(dir, rt)
+		if err != nil {
+			log.Fatalf("Error starting recorder: %v", err)
+		}
+		log.Printf("Recording requests to %v", dir)
+		rt = rec
+	}
+
+	var exporterOpts []exporter.Option
+	if baseURL := os.Getenv("LMGUIDE_URL"); baseURL != "" {
+		exporterOpts = append(exporterOpts, exporter.WithClientOptions(greatriverenergy.WithBaseURL(baseURL)))
+	}
+
+	if value := os.Getenv("ALL_HISTORY_TYPES"); value != "" {
+		all, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Error parsing ALL_HISTORY_TYPES: %v", err)
+		}
+		if all {
+			exporterOpts = append(exporterOpts, exporter.WithAllHistoryTypes())
+		}
+	}
+	if value := os.Getenv("MAX_CONCURRENCY"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("Error parsing MAX_CONCURRENCY: %v", err)
+		}
+		exporterOpts = append(exporterOpts, exporter.WithConcurrency(n))
+	}
+	if value := os.Getenv("METRICS_SCHEMA"); value != "" {
+		// e.g. "v2", or "v1,v2" while migrating
+		var schemas []exporter.MetricSchema
+		for _, version := range strings.Split(value, ",") {
+			n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(version), "v"))
+			if err != nil || n < int(exporter.MetricSchemaV1) || n > int(exporter.MetricSchemaV2) {
+				log.Fatalf("Error parsing METRICS_SCHEMA: unknown version %q", version)
+			}
+			schemas = append(schemas, exporter.MetricSchema(n))
+		}
+		exporterOpts = append(exporterOpts, exporter.WithMetricSchemas(schemas...))
+	}
+
+	// Keep a session warm for each history retrieval in progress at once, however many types of history are collected,
+	// and share them with /history
+	exporterOpts = append(exporterOpts, exporter.WithClientOptions(greatriverenergy.WithSessionPool(0, 0)))
+
+	// Limit the rate of requests made by every client together
+	rate, burst := 2.0, 4
+	if value := os.Getenv("RATE_LIMIT"); value != "" {
+		var err error
+		if rate, err = strconv.ParseFloat(value, 64); err != nil {
+			log.Fatalf("Error parsing RATE_LIMIT: %v", err)
+		}
+	}
+	if value := os.Getenv("RATE_LIMIT_BURST"); value != "" {
+		var err error
+		if burst, err = strconv.Atoi(value); err != nil {
+			log.Fatalf("Error parsing RATE_LIMIT_BURST: %v", err)
+		}
+	}
+	limiter := greatriverenergy.NewRateLimiter(rate, burst)
+	exporterOpts = append(exporterOpts, exporter.WithClientOptions(greatriverenergy.WithRateLimiter(limiter)))
+	limiterCollector := exporter.NewRateLimiter(limiter)
 
-	realtime := prometheus.NewRegistry()
-	realtime.MustRegister(exporter.NewRealtime(rt))
+	// Stop making requests for a while if the site seems to be down
+	breaker := greatriverenergy.NewCircuitBreaker(5, time.Minute)
+	exporterOpts = append(exporterOpts, exporter.WithClientOptions(greatriverenergy.WithCircuitBreaker(breaker)))
+	breakerCollector := exporter.NewCircuitBreaker(breaker)
+
+	// Past days of history never change, so keep them rather than retrieving them again on every scrape
+	var cache greatriverenergy.HistoryCache
+	if dir := os.Getenv("HISTORY_CACHE_DIR"); dir != "" {
+		var err error
+		if cache, err = greatriverenergy.NewDiskHistoryCache(dir); err != nil {
+			log.Fatalf("Error opening history cache: %v", err)
+		}
+		log.Printf("Caching history in %v", dir)
+	} else {
+		cache = greatriverenergy.NewMemoryHistoryCache()
+	}
+	historyCache := exporter.NewHistoryCache(cache)
+	exporterOpts = append(exporterOpts, exporter.WithClientOptions(greatriverenergy.WithHistoryCache(historyCache)))
+
+	// Retrieve the realtime pages in the background, so that scrapes never wait on the site
+	var intervals exporter.PollIntervals
+	for env, interval := range map[string]*time.Duration{
+		"SCHEDULE_INTERVAL":    &intervals.Schedule,
+		"SHED_COUNTS_INTERVAL": &intervals.ShedCounts,
+		"HISTORY_INTERVAL":     &intervals.History,
+	} {
+		if value := os.Getenv(env); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				log.Fatalf("Error parsing %v: %v", env, err)
+			}
+			*interval = d
+		}
+	}
+	realtimeCollector := exporter.NewRealtime(rt, exporterOpts...)
+	realtimeCollector.Start(context.Background(), intervals)
+
+	buildInfo := exporter.NewBuildInfo()
 
 	opts := promhttp.HandlerOpts{
 		EnableOpenMetrics: true,
 	}
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.HandlerFor(realtime, opts))
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		// Stop waiting on the site before Prometheus stops waiting on us
+		ctx, cancel := exporter.ScrapeContext(r)
+		defer cancel()
+
+		realtime := realtimeCollector.WithContext(ctx)
+		if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+			t, err := parseTimestamp(asOf)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid as_of: %v", err), http.StatusBadRequest)
+				return
+			}
+			realtime = realtime.AsOf(t)
+		}
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(realtime)
+		reg.MustRegister(buildInfo)
+		reg.MustRegister(historyCache)
+		reg.MustRegister(breakerCollector)
+		reg.MustRegister(limiterCollector)
+		promhttp.HandlerFor(reg, opts).ServeHTTP(w, r)
+	})
 
 	mux.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query()
@@ -31,9 +167,14 @@ func main() {
 			days = 7
 		}
 
-		reg := prometheus.NewRegistry()
-		reg.MustRegister(exporter.NewHistory(rt, days))
-		promhttp.HandlerFor(reg, opts).ServeHTTP(w, r)
+		ctx, cancel := exporter.ScrapeContext(r)
+		defer cancel()
+
+		// Write the samples as they are retrieved rather than gathering a registry, which would hold all of them
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		if _, err := exporter.NewHistory(rt, days, exporterOpts...).WithContext(ctx).WriteTo(w); err != nil {
+			log.Printf("Error writing history: %v", err)
+		}
 	})
 
 	var addr string
@@ -50,3 +191,11 @@ func main() {
 		log.Fatalf("Error starting HTTP server: %v", err)
 	}
 }
+
+// parseTimestamp parses a time given as RFC 3339 or as seconds since the epoch
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*1e9)), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}